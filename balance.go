@@ -0,0 +1,68 @@
+package spectrum
+
+import (
+	"math"
+	"strings"
+)
+
+// measureStereo returns the energy balance between the first two channels,
+// -1 for all left to +1 for all right, and their correlation: +1 for
+// identical channels, 0 for unrelated ones and -1 for opposite phase. Mono
+// input is centered and fully correlated; a silent chunk is centered and
+// uncorrelated.
+func measureStereo(buffers [][]float64) (balance, correlation float64) {
+	if len(buffers) < 2 {
+		return 0, 1
+	}
+
+	var left, right, cross float64
+	for i, l := range buffers[0] {
+		r := buffers[1][i]
+		left += l * l
+		right += r * r
+		cross += l * r
+	}
+	if total := left + right; total > 0 {
+		balance = (right - left) / total
+	}
+	if left > 0 && right > 0 {
+		correlation = cross / math.Sqrt(left*right)
+	}
+	return balance, correlation
+}
+
+// Balance is the left/right energy balance of the latest chunk, -1 (full
+// left) to +1 (full right); 0 for mono.
+func (v *Visualizer) Balance() float64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.balance
+}
+
+// Correlation is the phase correlation of the latest chunk's channels, -1 to
+// +1. Values near -1 point to a polarity problem that cancels out in mono.
+func (v *Visualizer) Correlation() float64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.correlation
+}
+
+// balanceLine draws the ShowBalance meter across Width: a track with its
+// center marked and the balance as a marker on it.
+func (v *Visualizer) balanceLine() string {
+	width := v.config.Width
+	center := int(math.Round(float64(width-1) / 2))
+	marker := int(math.Round((v.balance + 1) / 2 * float64(width-1)))
+	var sb strings.Builder
+	for col := range width {
+		switch {
+		case col == marker:
+			sb.WriteByte('o')
+		case col == center:
+			sb.WriteByte('|')
+		default:
+			sb.WriteByte('-')
+		}
+	}
+	return sb.String()
+}
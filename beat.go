@@ -0,0 +1,43 @@
+package spectrum
+
+import "time"
+
+const beatMinEnergy = 1e-6
+
+// beatDetector flags chunks whose energy jumps above the average of the
+// preceding second of audio.
+type beatDetector struct {
+	history []float64
+	pos     int
+	filled  int
+	last    time.Duration
+	seen    bool
+}
+
+func newBeatDetector(chunksPerSecond int) *beatDetector {
+	return &beatDetector{history: make([]float64, max(chunksPerSecond, 1))}
+}
+
+func (b *beatDetector) detect(energy float64, at time.Duration, sensitivity float64, minInterval time.Duration) bool {
+	avg := 0.0
+	for _, e := range b.history[:b.filled] {
+		avg += e
+	}
+	if b.filled > 0 {
+		avg /= float64(b.filled)
+	}
+
+	beat := energy > beatMinEnergy &&
+		energy > avg*sensitivity &&
+		(!b.seen || at-b.last >= minInterval)
+
+	b.history[b.pos] = energy
+	b.pos = (b.pos + 1) % len(b.history)
+	b.filled = min(b.filled+1, len(b.history))
+
+	if beat {
+		b.last = at
+		b.seen = true
+	}
+	return beat
+}
@@ -0,0 +1,34 @@
+package spectrum
+
+import (
+	"math"
+	"testing"
+)
+
+// TestBeatPerClick plays a 120 BPM click track over a quiet tone and expects
+// OnBeat to fire once for every click.
+func TestBeatPerClick(t *testing.T) {
+	const sampleRate, chunk = 44100, 1024
+	const clicks = 8
+	clickEvery := sampleRate / 2
+
+	beats := 0
+	v := New(Config{SampleRate: sampleRate, ChunkSize: chunk, OnBeat: func() { beats++ }})
+
+	total := clicks * clickEvery
+	samples := make([]float64, chunk)
+	for start := 0; start+chunk <= total; start += chunk {
+		for i := range samples {
+			n := start + i
+			samples[i] = 0.01 * math.Sin(2*math.Pi*220*float64(n)/sampleRate)
+			if n%clickEvery < 256 {
+				samples[i] = 0.8
+			}
+		}
+		v.Update(samples)
+	}
+
+	if beats != clicks {
+		t.Errorf("got %d beats for %d clicks", beats, clicks)
+	}
+}
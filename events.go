@@ -0,0 +1,103 @@
+package spectrum
+
+import (
+	"context"
+	"time"
+)
+
+// eventBuffer is how many events a slow Events receiver may fall behind.
+const eventBuffer = 16
+
+// Event is one of FrameEvent, TrackEvent or ErrorEvent.
+type Event interface {
+	// Time is when the event happened.
+	Time() time.Time
+	event()
+}
+
+// FrameEvent carries a rendered frame, as delivered by Frames.
+type FrameEvent struct {
+	At    time.Time
+	Frame string
+}
+
+// TrackEvent reports changed track metadata, as delivered by TrackChanges.
+type TrackEvent struct {
+	At    time.Time
+	Track TrackInfo
+}
+
+// ErrorEvent reports a read or ffmpeg failure. The stream may recover from
+// it through a reconnect; if it does not, the channel closes right after.
+type ErrorEvent struct {
+	At  time.Time
+	Err error
+}
+
+func (e FrameEvent) Time() time.Time { return e.At }
+func (e TrackEvent) Time() time.Time { return e.At }
+func (e ErrorEvent) Time() time.Time { return e.At }
+
+func (FrameEvent) event() {}
+func (TrackEvent) event() {}
+func (ErrorEvent) event() {}
+
+// Events merges frames, track changes and stream errors into one channel
+// for a single select loop. Like TrackChanges it polls the track without
+// ICYMetadata. Frames are skipped while the receiver is behind; track and
+// error events displace the oldest pending event instead. The channel is
+// closed, and the polling stopped, when ctx is done or the stream ends.
+func (v *Visualizer) Events(ctx context.Context) <-chan Event {
+	ch := make(chan Event, eventBuffer)
+	ctx, stopPoll := context.WithCancel(ctx)
+
+	v.subMu.Lock()
+	if v.eventSubs == nil {
+		v.eventSubs = make(map[chan Event]context.CancelFunc)
+	}
+	v.eventSubs[ch] = stopPoll
+	v.subMu.Unlock()
+
+	go func() {
+		v.pollTrack(ctx)
+		stopPoll()
+		v.subMu.Lock()
+		defer v.subMu.Unlock()
+		if _, ok := v.eventSubs[ch]; ok {
+			delete(v.eventSubs, ch)
+			close(ch)
+		}
+	}()
+
+	return ch
+}
+
+// publishEvent hands e to every Events receiver. Callers hold subMu.
+func (v *Visualizer) publishEvent(e Event) {
+	_, frame := e.(FrameEvent)
+	for ch := range v.eventSubs {
+		select {
+		case ch <- e:
+			continue
+		default:
+		}
+		if frame {
+			continue
+		}
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- e
+	}
+}
+
+// closeEvents closes every Events channel once the stream has ended and
+// stops its track polling. Callers hold subMu.
+func (v *Visualizer) closeEvents() {
+	for ch, stopPoll := range v.eventSubs {
+		delete(v.eventSubs, ch)
+		close(ch)
+		stopPoll()
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"os/exec"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/ant1kvar/spectrum"
 )
@@ -17,8 +16,7 @@ func main() {
 	streamURL := "http://s2-webradio.rockantenne.de/rockantenne"
 
 	cfg := spectrum.DefaultConfig()
-	cfg.Width = 60
-	cfg.Height = 12
+	cfg.AutoSize = true
 	cfg.Char = "|"
 	cfg.SmoothFactor = 0.9
 
@@ -50,20 +48,11 @@ func playAudio(ctx context.Context, streamURL string) {
 }
 
 func trackUpdater(ctx context.Context, vis *spectrum.Visualizer) {
-	ticker := time.NewTicker(3 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			track := vis.FetchTrack()
-			if track.Raw != "" {
-				fmt.Printf("\033[1;0H\033[KNow playing: %s\n", track.Raw)
-			} else {
-				fmt.Print("\033[1;0H\033[K")
-			}
+	for track := range vis.TrackChanges(ctx) {
+		if track.Raw != "" {
+			fmt.Printf("\033[1;0H\033[KNow playing: %s\n", track.Raw)
+		} else {
+			fmt.Print("\033[1;0H\033[K")
 		}
 	}
 }
@@ -0,0 +1,245 @@
+package spectrum
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	gifCellWidth  = 8
+	gifCellHeight = 16
+
+	spectrogramBandHeight = 4
+)
+
+var gifPalette = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xff},
+	color.RGBA{0x00, 0xc0, 0x00, 0xff},
+	color.RGBA{0x80, 0xe0, 0x00, 0xff},
+	color.RGBA{0xe0, 0xe0, 0x00, 0xff},
+	color.RGBA{0xff, 0x90, 0x00, 0xff},
+	color.RGBA{0xff, 0x20, 0x20, 0xff},
+}
+
+// ExportGIF records the running visualization for duration and writes it to
+// path as an animated GIF, one frame per FPS tick. Every frame has the cells
+// of the terminal frame, in whatever layout, orientation and style it uses.
+func (v *Visualizer) ExportGIF(ctx context.Context, path string, duration time.Duration) error {
+	interval := v.Config().tickInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	anim := &gif.GIF{}
+	delay := max(int(interval/(10*time.Millisecond)), 2)
+	deadline := time.After(duration)
+
+	for recording := true; recording; {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			recording = false
+		case <-ticker.C:
+			anim.Image = append(anim.Image, v.rasterize())
+			anim.Delay = append(anim.Delay, delay)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rasterize draws the frame renderFrame would, filling one pixel block for
+// every cell that holds a glyph, so the export has the terminal's layout,
+// orientation and band count.
+func (v *Visualizer) rasterize() *image.Paletted {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	width, height := v.config.Width, v.config.Height
+	img := image.NewPaletted(image.Rect(0, 0, width*gifCellWidth, height*gifCellHeight), gifPalette)
+
+	blank := v.config.BlankChar
+	if v.config.ASCIIFallback && !isASCII(blank) {
+		blank = " "
+	}
+	frame := ansiEscape.ReplaceAllString(v.renderFrame(v.smoothed), "")
+	levels := len(gifPalette) - 1
+
+	for row, line := range strings.SplitN(frame, "\n", height+1)[:height] {
+		col := 0
+		for _, cell := range line {
+			if col >= width {
+				break
+			}
+			if string(cell) != blank {
+				level, maxLevel := v.cellLevel(row, col)
+				idx := uint8(1 + min(max(level, 0)*levels/max(maxLevel+1, 1), levels-1))
+				for y := row * gifCellHeight; y < (row+1)*gifCellHeight; y++ {
+					for x := col*gifCellWidth + 1; x < (col+1)*gifCellWidth-1; x++ {
+						img.SetColorIndex(x, y, idx)
+					}
+				}
+			}
+			col++
+		}
+	}
+	return img
+}
+
+// cellLevel returns how far the cell at row and col lies from the base of
+// its bar and the furthest it can lie, as the renderers pass them to
+// colorFor. Callers hold mu.
+func (v *Visualizer) cellLevel(row, col int) (level, maxLevel int) {
+	height := v.config.Height
+	switch {
+	case v.config.Mode == ModeScope:
+		midline := (height - 1) / 2
+		return abs(row - midline), midline
+	case v.config.Orientation == OrientationHorizontal:
+		origin := 0
+		if len(v.smoothed) > 1 {
+			origin = v.config.Width / 2
+		}
+		if col < origin {
+			return origin - 1 - col, origin - 1
+		}
+		return col - origin, v.config.Width - origin - 1
+	case v.braille():
+		midline, _ := v.brailleMidline()
+		return abs(row - midline), midline - 1
+	case v.config.Layout == LayoutGround:
+		return height - 1 - row, height - 1
+	default:
+		midline := height / 2
+		return abs(row - midline), midline - 1
+	}
+}
+
+// ExportSpectrogram records the raw spectrum for window and writes it to
+// path as a PNG with time on the X axis and frequency rising along Y. Each
+// chunk becomes one pixel column; a ring buffer keeps only the latest window
+// of audio when the source is read faster than real time.
+func (v *Visualizer) ExportSpectrogram(ctx context.Context, path string, window time.Duration) error {
+	v.mu.RLock()
+	mode := v.config.Mode
+	v.mu.RUnlock()
+	if mode != ModeSpectrum {
+		return errors.New("spectrogram export requires ModeSpectrum")
+	}
+
+	chunks := int(window * time.Duration(v.config.SampleRate) / (time.Duration(v.config.ChunkSize) * time.Second))
+	ring := newFrameRing(max(chunks, 1))
+
+	v.mu.Lock()
+	v.recorder = ring
+	v.mu.Unlock()
+
+	err := sleepContext(ctx, window)
+
+	v.mu.Lock()
+	v.recorder = nil
+	v.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, v.spectrogramImage(ring.ordered())); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (v *Visualizer) spectrogramImage(frames [][]float64) *image.RGBA {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	bands := 1
+	if len(frames) > 0 {
+		bands = len(frames[0])
+	}
+	img := image.NewRGBA(image.Rect(0, 0, max(len(frames), 1), bands*spectrogramBandHeight))
+
+	for x, frame := range frames {
+		if v.config.PerFrameNormalize {
+			frame = normalizeFrame([][]float64{frame})[0]
+		}
+		for band, value := range frame {
+			c := heatColor(v.barLevel(value))
+			top := (bands - 1 - band) * spectrogramBandHeight
+			for y := top; y < top+spectrogramBandHeight; y++ {
+				img.Set(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+// heatColor maps 0..1 through black, blue, red and yellow to white.
+func heatColor(t float64) color.RGBA {
+	stops := []color.RGBA{
+		{0x00, 0x00, 0x00, 0xff},
+		{0x20, 0x00, 0xa0, 0xff},
+		{0xd0, 0x00, 0x40, 0xff},
+		{0xff, 0xc0, 0x00, 0xff},
+		{0xff, 0xff, 0xff, 0xff},
+	}
+
+	pos := t * float64(len(stops)-1)
+	i := min(int(pos), len(stops)-2)
+	frac := pos - float64(i)
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*frac)
+	}
+	a, b := stops[i], stops[i+1]
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), 0xff}
+}
+
+// frameRing keeps the most recent frames, oldest first.
+type frameRing struct {
+	frames [][]float64
+	start  int
+	size   int
+}
+
+func newFrameRing(capacity int) *frameRing {
+	return &frameRing{frames: make([][]float64, capacity)}
+}
+
+func (r *frameRing) push(frame []float64) {
+	idx := (r.start + r.size) % len(r.frames)
+	r.frames[idx] = frame
+	if r.size < len(r.frames) {
+		r.size++
+	} else {
+		r.start = (r.start + 1) % len(r.frames)
+	}
+}
+
+func (r *frameRing) ordered() [][]float64 {
+	result := make([][]float64, r.size)
+	for i := range result {
+		result[i] = r.frames[(r.start+i)%len(r.frames)]
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package spectrum
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// TestRasterizeMatchesRender expects every GIF cell to be filled exactly
+// where the terminal frame draws a glyph, in each layout and style.
+func TestRasterizeMatchesRender(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float64, 4096)
+	for i := range samples {
+		samples[i] = (rng.Float64()*2 - 1) * float64(i) / float64(len(samples))
+	}
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"mirror", Config{}},
+		{"ground", Config{Layout: LayoutGround}},
+		{"braille", Config{RenderStyle: StyleBraille}},
+		{"horizontal", Config{Orientation: OrientationHorizontal}},
+		{"scope", Config{Mode: ModeScope}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.RawFrame, cfg.Width, cfg.Height, cfg.SmoothFactor = true, 16, 8, 1
+			v := New(cfg)
+			v.Update(samples)
+
+			img := v.rasterize()
+			lines := strings.Split(v.RenderPlain(), "\n")
+			for row := range cfg.Height {
+				cells := []rune(lines[row])
+				for col := range cfg.Width {
+					x, y := col*gifCellWidth+gifCellWidth/2, row*gifCellHeight+gifCellHeight/2
+					filled := img.ColorIndexAt(x, y) != 0
+					if drawn := cells[col] != ' '; filled != drawn {
+						t.Errorf("cell %d,%d filled %v, rendered %q", row, col, filled, cells[col])
+					}
+				}
+			}
+		})
+	}
+}
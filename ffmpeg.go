@@ -0,0 +1,109 @@
+package spectrum
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var ErrFFmpegNotFound = errors.New("ffmpeg executable not found")
+
+const stderrTailLines = 20
+
+func isNotFound(err error) bool {
+	return errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist)
+}
+
+// terminate asks the process to exit with SIGTERM, falling back to Kill on
+// platforms that cannot deliver it. exec.Cmd.WaitDelay escalates to Kill if
+// the process ignores the signal.
+func terminate(p *os.Process) error {
+	if err := p.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return p.Kill()
+	}
+	return nil
+}
+
+// stderrTail keeps the last limit lines ffmpeg wrote to stderr, logging each
+// one at debug level as it arrives.
+type stderrTail struct {
+	logger *slog.Logger
+	limit  int
+
+	mu      sync.Mutex
+	partial []byte
+	lines   []string
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partial = append(t.partial, p...)
+	for {
+		i := bytes.IndexAny(t.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(t.partial[:i])); line != "" {
+			t.logger.Debug("ffmpeg stderr", "line", line)
+			t.lines = append(t.lines, line)
+			if over := len(t.lines) - t.limit; over > 0 {
+				t.lines = t.lines[over:]
+			}
+		}
+		t.partial = t.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (t *stderrTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := t.lines
+	if partial := strings.TrimSpace(string(t.partial)); partial != "" {
+		lines = append(lines[:len(lines):len(lines)], partial)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// processReader reads a child's stdout and, once it hits EOF, reaps the
+// process so that a non-zero exit is reported together with its stderr.
+type processReader struct {
+	r      io.Reader
+	cmd    *exec.Cmd
+	stderr *stderrTail
+
+	once sync.Once
+	err  error
+}
+
+func (p *processReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if err == io.EOF || errors.Is(err, fs.ErrClosed) {
+		return n, p.wait()
+	}
+	return n, err
+}
+
+func (p *processReader) wait() error {
+	p.once.Do(func() {
+		p.err = ErrStreamEnded
+		if err := p.cmd.Wait(); err != nil {
+			if msg := p.stderr.String(); msg != "" {
+				p.err = fmt.Errorf("ffmpeg exited: %w: %s", err, msg)
+			} else {
+				p.err = fmt.Errorf("ffmpeg exited: %w", err)
+			}
+		}
+	})
+	return p.err
+}
@@ -0,0 +1,96 @@
+package spectrum
+
+import (
+	"math"
+	"math/bits"
+)
+
+func fft(x []complex128) {
+	n := len(x)
+	if n <= 1 {
+		return
+	}
+
+	shift := bits.UintSize - bits.Len(uint(n-1))
+	for i := range n {
+		j := int(bits.Reverse(uint(i)) >> shift)
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := -2 * math.Pi / float64(size)
+		for start := 0; start < n; start += size {
+			for k := range half {
+				w := complex(math.Cos(step*float64(k)), math.Sin(step*float64(k)))
+				a := x[start+k]
+				b := x[start+k+half] * w
+				x[start+k] = a + b
+				x[start+k+half] = a - b
+			}
+		}
+	}
+}
+
+func windowCoefficients(kind Window, n int) []float64 {
+	w := make([]float64, n)
+	if n == 1 {
+		w[0] = 1
+		return w
+	}
+	denom := float64(n - 1)
+	for i := range w {
+		x := 2 * math.Pi * float64(i) / denom
+		switch kind {
+		case WindowHann:
+			w[i] = 0.5 - 0.5*math.Cos(x)
+		case WindowHamming:
+			w[i] = 0.54 - 0.46*math.Cos(x)
+		case WindowBlackman:
+			w[i] = 0.42 - 0.5*math.Cos(x) + 0.08*math.Cos(2*x)
+		default:
+			w[i] = 1
+		}
+	}
+	return w
+}
+
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// bandValue returns the strongest bin in the fractional range [lo, hi).
+// Ranges narrower than one bin are linearly interpolated so that columns
+// never collapse to zero when there are more columns than bins.
+func bandValue(mags []float64, lo, hi float64) float64 {
+	last := len(mags) - 1
+	if hi-lo < 1 {
+		pos := math.Min((lo+hi)/2, float64(last))
+		i := int(pos)
+		if i >= last {
+			return mags[last]
+		}
+		frac := pos - float64(i)
+		return mags[i]*(1-frac) + mags[i+1]*frac
+	}
+
+	peak := 0.0
+	for i := int(math.Ceil(lo)); i < int(math.Ceil(hi)) && i <= last; i++ {
+		peak = max(peak, mags[i])
+	}
+	return peak
+}
+
+// aWeighting returns the IEC 61672 A-weighting gain at hz as a linear factor,
+// normalized to 1 at 1 kHz.
+func aWeighting(hz float64) float64 {
+	f2 := hz * hz
+	ra := 12194.0 * 12194.0 * f2 * f2 /
+		((f2 + 20.6*20.6) * math.Sqrt((f2+107.7*107.7)*(f2+737.9*737.9)) * (f2 + 12194.0*12194.0))
+	return ra * math.Pow(10, 2.0/20)
+}
@@ -0,0 +1,44 @@
+package spectrum
+
+import (
+	"math"
+	"testing"
+)
+
+// peakWidth counts the bands above a tenth of the loudest one.
+func peakWidth(bands []float64) int {
+	peak := 0.0
+	for _, value := range bands {
+		peak = max(peak, value)
+	}
+	width := 0
+	for _, value := range bands {
+		if value > peak/10 {
+			width++
+		}
+	}
+	return width
+}
+
+// TestWindowNarrowsPeak feeds a tone halfway between two FFT bins, the worst
+// case for leakage, and expects Hann to confine it to fewer bands than no
+// window at all.
+func TestWindowNarrowsPeak(t *testing.T) {
+	const sampleRate, chunk = 44100, 1024
+	freq := sampleRate * 100.5 / chunk
+	samples := make([]float64, chunk)
+	for i := range samples {
+		samples[i] = 0.5 * math.Sin(2*math.Pi*freq*float64(i)/sampleRate)
+	}
+
+	widths := make(map[Window]int)
+	for _, window := range []Window{WindowNone, WindowHann} {
+		v := New(Config{Mode: ModeSpectrum, Window: window, Width: 64, SmoothFactor: 1, ChunkSize: chunk, SampleRate: sampleRate})
+		v.Update(samples)
+		widths[window] = peakWidth(v.GetWaveform())
+	}
+
+	if widths[WindowHann] >= widths[WindowNone] {
+		t.Errorf("hann peak spans %d bands, none %d; want hann narrower", widths[WindowHann], widths[WindowNone])
+	}
+}
@@ -0,0 +1,79 @@
+package spectrum
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+type SampleFormat string
+
+const (
+	FormatS16LE SampleFormat = "s16le"
+	FormatS24LE SampleFormat = "s24le"
+	FormatS32LE SampleFormat = "s32le"
+	FormatF32LE SampleFormat = "f32le"
+)
+
+// Endianness is the byte order of the PCM samples. SampleFormat names the
+// little-endian variant; with EndianBig the same encoding is read big-endian.
+type Endianness string
+
+const (
+	EndianLittle Endianness = "le"
+	EndianBig    Endianness = "be"
+)
+
+// pcmFormat is the sample encoding of one stream. StartFromWAV and
+// StartFromTone decode with their own instead of the Config's.
+type pcmFormat struct {
+	format SampleFormat
+	endian Endianness
+}
+
+func (e Endianness) byteOrder() binary.ByteOrder {
+	if e == EndianBig {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// ffmpegFormat is the format name ffmpeg uses for f in byte order e, such as
+// "s16be".
+func (f SampleFormat) ffmpegFormat(e Endianness) string {
+	return strings.TrimSuffix(string(f), "le") + string(e)
+}
+
+func (f SampleFormat) bytesPerSample() int {
+	switch f {
+	case FormatS24LE:
+		return 3
+	case FormatS32LE, FormatF32LE:
+		return 4
+	default:
+		return 2
+	}
+}
+
+func (f SampleFormat) codec(e Endianness) string {
+	return "pcm_" + f.ffmpegFormat(e)
+}
+
+// decodeSample converts one sample in byte order to the range [-1, 1).
+func (f SampleFormat) decodeSample(b []byte, order binary.ByteOrder) float64 {
+	switch f {
+	case FormatS24LE:
+		lo, hi := b[0], b[2]
+		if order == binary.BigEndian {
+			lo, hi = hi, lo
+		}
+		value := int32(uint32(lo)<<8|uint32(b[1])<<16|uint32(hi)<<24) >> 8
+		return float64(value) / 8388608.0
+	case FormatS32LE:
+		return float64(int32(order.Uint32(b))) / 2147483648.0
+	case FormatF32LE:
+		return float64(math.Float32frombits(order.Uint32(b)))
+	default:
+		return float64(int16(order.Uint16(b))) / 32768.0
+	}
+}
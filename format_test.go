@@ -0,0 +1,64 @@
+package spectrum
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestDecodeSampleS16(t *testing.T) {
+	tests := []struct {
+		bytes []byte
+		want  float64
+	}{
+		{[]byte{0x00, 0x80}, -1},
+		{[]byte{0xFF, 0x7F}, 32767.0 / 32768.0},
+		{[]byte{0x00, 0x00}, 0},
+		{[]byte{0xFF, 0xFF}, -1.0 / 32768.0},
+		{[]byte{0x00, 0x40}, 0.5},
+	}
+
+	for _, tt := range tests {
+		if got := FormatS16LE.decodeSample(tt.bytes, binary.LittleEndian); got != tt.want {
+			t.Errorf("decodeSample(% X) = %v, want %v", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+// encodeLE writes value in format f, little-endian, for the decoder tests.
+func encodeLE(f SampleFormat, value float64) []byte {
+	b := make([]byte, f.bytesPerSample())
+	switch f {
+	case FormatS24LE:
+		v := int32(value * 8388608)
+		b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+	case FormatS32LE:
+		binary.LittleEndian.PutUint32(b, uint32(int32(value*2147483648)))
+	case FormatF32LE:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(value)))
+	default:
+		binary.LittleEndian.PutUint16(b, uint16(int16(value*32768)))
+	}
+	return b
+}
+
+func TestDecodeSampleEndianness(t *testing.T) {
+	formats := []SampleFormat{FormatS16LE, FormatS24LE, FormatS32LE, FormatF32LE}
+	values := []float64{-1, -0.5, -0.25, 0, 0.125, 0.5, 0.75}
+
+	for _, f := range formats {
+		for _, value := range values {
+			le := encodeLE(f, value)
+			be := slices.Clone(le)
+			slices.Reverse(be)
+
+			if got := f.decodeSample(le, EndianLittle.byteOrder()); got != value {
+				t.Errorf("%s le decodeSample(% X) = %v, want %v", f, le, got, value)
+			}
+			if got := f.decodeSample(be, EndianBig.byteOrder()); got != value {
+				t.Errorf("%s be decodeSample(% X) = %v, want %v", f, be, got, value)
+			}
+		}
+	}
+}
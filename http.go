@@ -0,0 +1,82 @@
+package spectrum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const httpShutdownTimeout = 5 * time.Second
+
+// Handler serves live data from the running visualizer: /events is a
+// Server-Sent Events stream of StreamJSON frames and /track returns the
+// current TrackInfo. Every client reads the same shared state, so any number
+// can subscribe without starting another ffmpeg.
+func (v *Visualizer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", v.serveEvents)
+	mux.HandleFunc("/track", v.serveTrack)
+	return mux
+}
+
+// ListenAndServe serves Handler on addr until ctx is done.
+func (v *Visualizer) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     v.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return ctx.Err()
+	}
+}
+
+func (v *Visualizer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	v.tickFrames(r.Context(), func(frame jsonFrame) error {
+		data, err := json.Marshal(frame)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+}
+
+func (v *Visualizer) serveTrack(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v.GetTrack())
+}
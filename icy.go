@@ -0,0 +1,109 @@
+package spectrum
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// openICY requests the stream with inline Shoutcast/Icecast metadata and
+// returns the audio with the metadata blocks stripped out. Titles found in
+// the metadata are passed to onTitle as they arrive.
+func openICY(ctx context.Context, streamURL string, onTitle func(string)) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Icy-MetaData", "1")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("stream request failed: %s", resp.Status)
+	}
+
+	metaint, err := strconv.Atoi(resp.Header.Get("icy-metaint"))
+	if err != nil || metaint <= 0 {
+		return resp.Body, nil
+	}
+
+	return &icyReader{
+		r:         resp.Body,
+		closer:    resp.Body,
+		metaint:   metaint,
+		remaining: metaint,
+		onTitle:   onTitle,
+	}, nil
+}
+
+type icyReader struct {
+	r         io.Reader
+	closer    io.Closer
+	metaint   int
+	remaining int
+	onTitle   func(string)
+}
+
+func (ir *icyReader) Read(p []byte) (int, error) {
+	if ir.remaining == 0 {
+		if err := ir.readMetadata(); err != nil {
+			return 0, err
+		}
+		ir.remaining = ir.metaint
+	}
+
+	if len(p) > ir.remaining {
+		p = p[:ir.remaining]
+	}
+	n, err := ir.r.Read(p)
+	ir.remaining -= n
+	return n, err
+}
+
+func (ir *icyReader) Close() error {
+	return ir.closer.Close()
+}
+
+func (ir *icyReader) readMetadata() error {
+	var size [1]byte
+	if _, err := io.ReadFull(ir.r, size[:]); err != nil {
+		return err
+	}
+	if size[0] == 0 {
+		return nil
+	}
+
+	block := make([]byte, int(size[0])*16)
+	if _, err := io.ReadFull(ir.r, block); err != nil {
+		return err
+	}
+
+	if title, ok := parseICYTitle(strings.TrimRight(string(block), "\x00")); ok {
+		ir.onTitle(title)
+	}
+	return nil
+}
+
+func parseICYTitle(meta string) (string, bool) {
+	const key = "StreamTitle='"
+	start := strings.Index(meta, key)
+	if start < 0 {
+		return "", false
+	}
+
+	rest := meta[start+len(key):]
+	end := strings.Index(rest, "';")
+	if end < 0 {
+		end = strings.LastIndex(rest, "'")
+	}
+	if end < 0 {
+		end = len(rest)
+	}
+	return rest[:end], true
+}
@@ -0,0 +1,41 @@
+package spectrum
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonFrame is one line of StreamJSON output.
+type jsonFrame struct {
+	T    int64     `json:"t"`
+	Bars []float64 `json:"bars"`
+}
+
+// StreamJSON writes the smoothed bars to w as newline-delimited JSON, one
+// {"t":<unix ms>,"bars":[...]} object per FPS tick, until ctx is done.
+func (v *Visualizer) StreamJSON(ctx context.Context, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return v.tickFrames(ctx, func(frame jsonFrame) error {
+		return enc.Encode(frame)
+	})
+}
+
+// tickFrames calls emit with the current bars on every FPS tick until ctx is
+// done or emit fails.
+func (v *Visualizer) tickFrames(ctx context.Context, emit func(jsonFrame) error) error {
+	ticker := time.NewTicker(v.Config().tickInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			if err := emit(jsonFrame{T: now.UnixMilli(), Bars: v.GetWaveform()}); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package spectrum
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler is the default Logger handler: it drops every record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,63 @@
+package spectrum
+
+import "sync"
+
+const outputQueueSize = 2
+
+// asyncWriter hands frame writes to a dedicated goroutine so a slow Output
+// or Renderer never stalls the stream; frames are dropped while the queue is
+// full.
+type asyncWriter struct {
+	queue chan func() error
+	done  chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func newAsyncWriter() *asyncWriter {
+	a := &asyncWriter{
+		queue: make(chan func() error, outputQueueSize),
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *asyncWriter) run() {
+	defer close(a.done)
+	for write := range a.queue {
+		if err := write(); err != nil {
+			a.mu.Lock()
+			a.err = err
+			a.mu.Unlock()
+			for range a.queue {
+			}
+			return
+		}
+	}
+}
+
+// write queues a frame write, reporting false if it was dropped. It returns
+// the first error from an earlier write.
+func (a *asyncWriter) write(frame func() error) (bool, error) {
+	a.mu.Lock()
+	err := a.err
+	a.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	select {
+	case a.queue <- frame:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// close stops accepting frames and waits for queued ones to be written.
+func (a *asyncWriter) close() {
+	close(a.queue)
+	<-a.done
+}
@@ -0,0 +1,806 @@
+package spectrum
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const colorReset = "\033[0m"
+
+var DefaultPalette = []string{
+	"\033[32m",
+	"\033[92m",
+	"\033[33m",
+	"\033[93m",
+	"\033[31m",
+}
+
+// RainbowPalette suits ColorModeFrequency, running red to magenta.
+var RainbowPalette = []string{
+	"\033[31m",
+	"\033[33m",
+	"\033[32m",
+	"\033[36m",
+	"\033[34m",
+	"\033[35m",
+}
+
+func (v *Visualizer) renderFrame(channels [][]float64) string {
+	var sb strings.Builder
+	sb.Grow(v.config.Width * v.config.Height * 4)
+
+	if !v.config.RawFrame {
+		fmt.Fprintf(&sb, "\033[%d;%dH", v.config.OriginRow, v.config.OriginCol)
+		sb.WriteString("\033[?25l")
+	}
+	header := sb.Len()
+
+	channels = v.prepareFrame(channels)
+
+	switch {
+	case v.config.Mode == ModeScope:
+		v.renderScope(&sb, channels)
+	case v.config.Orientation == OrientationHorizontal:
+		v.renderHorizontal(&sb, channels)
+	case v.braille():
+		v.renderBraille(&sb, channels)
+	case v.config.Layout == LayoutGround:
+		v.renderGround(&sb, channels)
+	default:
+		v.renderVertical(&sb, channels)
+	}
+
+	if v.showAxis() {
+		sb.WriteString(v.axisLine())
+		sb.WriteByte('\n')
+	}
+
+	if v.config.ShowBalance {
+		sb.WriteString(v.balanceLine())
+		sb.WriteByte('\n')
+	}
+
+	if v.config.ShowStatus {
+		sb.WriteString(v.statusLine())
+		sb.WriteByte('\n')
+	}
+
+	frame := sb.String()
+	if v.config.OriginCol > 1 && !v.config.RawFrame {
+		frame = frame[:header] + positionLines(frame[header:], v.config.OriginRow, v.config.OriginCol)
+	}
+	return frame
+}
+
+// prepareFrame applies NoiseFloor and PerFrameNormalize, in that order.
+func (v *Visualizer) prepareFrame(channels [][]float64) [][]float64 {
+	if v.config.NoiseFloor > 0 {
+		channels = gateFrame(channels, v.config.NoiseFloor)
+	}
+	if v.config.PerFrameNormalize {
+		channels = normalizeFrame(channels)
+	}
+	return channels
+}
+
+// BarHeights returns the length of every bar Render draws, one per bar
+// position and from the same state and scaling, in the renderer's own units:
+//
+//   - block bars count whole cells per column: 0 to Height in the ground
+//     layout, and in the mirror layout 0 to Height/2-1 rows beyond the
+//     midline row, which is drawn whenever the height is above 0 (the left
+//     channel's upper half with stereo). SubCell partial cells are not
+//     counted.
+//   - Braille bars count dots per dot column, two per cell: 0 to Height*4
+//     in the ground layout, and in the mirror layout the dots beyond the
+//     middle dot row, as for blocks.
+//   - horizontal bars count cells per row across Width, or across the left
+//     half for the left channel with stereo.
+//
+// Gap positions are 0. ModeScope draws a trace rather than bars and returns
+// nil.
+func (v *Visualizer) BarHeights() []int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	channels := v.prepareFrame(v.smoothed)
+	var heights []int
+	switch {
+	case v.config.Mode == ModeScope:
+		return nil
+	case v.config.Orientation == OrientationHorizontal:
+		left, right, _, _ := v.horizontalExtents(channels)
+		heights = right
+		if len(channels) > 1 {
+			heights = left
+		}
+	case v.braille():
+		heights, _, _ = v.brailleExtents(channels)
+	default:
+		var extents []float64
+		if v.config.Layout == LayoutGround {
+			extents = v.groundExtents(channels)
+		} else {
+			extents, _ = v.verticalExtents(channels)
+		}
+		heights = make([]int, len(extents))
+		for col, extent := range extents {
+			heights[col] = int(extent)
+		}
+	}
+	for pos, height := range heights {
+		heights[pos] = max(height, 0)
+	}
+	return heights
+}
+
+// gateFrame returns a copy of channels with every value whose magnitude is
+// below floor set to zero, so residual noise draws nothing at all rather
+// than a flickering sliver. It runs before normalizeFrame, which would
+// otherwise blow the noise up to full height.
+func gateFrame(channels [][]float64, floor float64) [][]float64 {
+	gated := makeChannels(len(channels), len(channels[0]))
+	for c, ch := range channels {
+		for i, value := range ch {
+			if math.Abs(value) >= floor {
+				gated[c][i] = value
+			}
+		}
+	}
+	return gated
+}
+
+// normalizeFrame returns a copy of channels scaled so that the largest
+// magnitude across all of them is 1. A silent frame stays all zero.
+func normalizeFrame(channels [][]float64) [][]float64 {
+	peak := 0.0
+	for _, ch := range channels {
+		for _, value := range ch {
+			peak = max(peak, math.Abs(value))
+		}
+	}
+
+	scaled := makeChannels(len(channels), len(channels[0]))
+	if peak == 0 {
+		return scaled
+	}
+	for c, ch := range channels {
+		for i, value := range ch {
+			scaled[c][i] = value / peak
+		}
+	}
+	return scaled
+}
+
+// FrameSize returns the rows and columns a rendered frame covers from its
+// origin: Height rows of bars plus the axis and status lines when shown, and
+// Width columns. The status line is not clipped, so a long title can run
+// past Width.
+func (v *Visualizer) FrameSize() (rows, cols int) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.config.Height + v.extraRows(), v.config.Width
+}
+
+// extraRows counts the lines renderFrame writes below the bars.
+func (v *Visualizer) extraRows() int {
+	rows := 0
+	if v.showAxis() {
+		rows++
+	}
+	if v.config.ShowBalance {
+		rows++
+	}
+	if v.config.ShowStatus {
+		rows++
+	}
+	return rows
+}
+
+// positionLines moves the cursor to col at the start of every line after the
+// first, since a newline alone returns to the terminal's first column.
+func positionLines(body string, row, col int) string {
+	var sb strings.Builder
+	sb.Grow(len(body) + strings.Count(body, "\n")*10)
+	for i, line := range strings.SplitAfter(body, "\n") {
+		if i > 0 && line != "" {
+			fmt.Fprintf(&sb, "\033[%d;%dH", row+i, col)
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+func (v *Visualizer) renderVertical(sb *strings.Builder, channels [][]float64) {
+	midline := v.config.Height / 2
+	up, down := v.verticalExtents(channels)
+
+	for row := range v.config.Height {
+		color := ""
+		for col := range v.config.Width {
+			extent := cellExtent(up[col], down[col], row, midline)
+			offset := abs(row - midline)
+			glyph := v.verticalGlyph(offset, extent, row > midline)
+			if glyph == "" {
+				v.writeBlank(sb, &color)
+				continue
+			}
+			if v.config.Color {
+				if c := v.colorFor(offset, midline-1, col, v.config.Width); c != color {
+					sb.WriteString(c)
+					color = c
+				}
+			}
+			sb.WriteString(glyph)
+		}
+		if v.config.Color {
+			sb.WriteString(colorReset)
+		}
+		sb.WriteByte('\n')
+	}
+}
+
+// renderGround draws classic bars rising from the bottom row over the full
+// Height. Stereo input is mixed, since both channels share the same floor.
+func (v *Visualizer) renderGround(sb *strings.Builder, channels [][]float64) {
+	extents := v.groundExtents(channels)
+	for row := range v.config.Height {
+		offset := v.config.Height - 1 - row
+		color := ""
+		for col := range v.config.Width {
+			glyph := v.groundGlyph(offset, extents[col])
+			if glyph == "" {
+				v.writeBlank(sb, &color)
+				continue
+			}
+			if v.config.Color {
+				if c := v.colorFor(offset, v.config.Height-1, col, v.config.Width); c != color {
+					sb.WriteString(c)
+					color = c
+				}
+			}
+			sb.WriteString(glyph)
+		}
+		if v.config.Color {
+			sb.WriteString(colorReset)
+		}
+		sb.WriteByte('\n')
+	}
+}
+
+// groundExtents is the bar length of every column in cells for the ground
+// layout, or -1 for gap columns.
+func (v *Visualizer) groundExtents(channels [][]float64) []float64 {
+	values := mixChannels(channels)
+	extents := make([]float64, v.config.Width)
+	for col := range v.config.Width {
+		extents[col] = -1
+		if band, ok := v.bandAt(col, len(values)); ok {
+			extents[col] = v.barLevel(values[band]) * float64(v.config.Height)
+		}
+	}
+	return extents
+}
+
+// renderScope traces the signal across Width with positive samples above the
+// midline, joining neighboring points with vertical runs so steep edges stay
+// connected. Stereo input is mixed into a single trace.
+func (v *Visualizer) renderScope(sb *strings.Builder, channels [][]float64) {
+	values := mixChannels(channels)
+	center := float64(v.config.Height-1) / 2
+	rows := make([]int, v.config.Width)
+	for col := range v.config.Width {
+		rows[col] = -1
+		if band, ok := v.bandAt(col, len(values)); ok {
+			rows[col] = int(math.Round(center - v.scopeLevel(values[band])*center))
+		}
+	}
+
+	midline := int(center)
+	for row := range v.config.Height {
+		color := ""
+		for col := range v.config.Width {
+			if !scopeCovers(rows, col, row) {
+				v.writeBlank(sb, &color)
+				continue
+			}
+			if v.config.Color {
+				if c := v.colorFor(abs(row-midline), midline, col, v.config.Width); c != color {
+					sb.WriteString(c)
+					color = c
+				}
+			}
+			sb.WriteString(v.glyph(v.config.Char))
+		}
+		if v.config.Color {
+			sb.WriteString(colorReset)
+		}
+		sb.WriteByte('\n')
+	}
+}
+
+// scopeCovers reports whether the trace passes through row in col: at the
+// column's own point or on the run up to its left neighbor's point.
+func scopeCovers(rows []int, col, row int) bool {
+	at := rows[col]
+	if at < 0 {
+		return false
+	}
+	if row == at {
+		return true
+	}
+	if col == 0 || rows[col-1] < 0 {
+		return false
+	}
+	prev := rows[col-1]
+	return row > min(at, prev) && row < max(at, prev)
+}
+
+// groundGlyph returns the glyph for the cell offset rows above the bottom of
+// a bar with the given extent, or "" for an empty cell.
+func (v *Visualizer) groundGlyph(offset int, extent float64) string {
+	if float64(offset+1) <= extent {
+		if v.subCell() {
+			return "█"
+		}
+		return v.barChar(offset, int(extent))
+	}
+	if !v.subCell() || extent <= 0 || offset != int(extent) {
+		return ""
+	}
+	return lowerBlocks[int((extent-float64(offset))*8)]
+}
+
+// verticalExtents returns, per column, how many cells the bar reaches above
+// (upper channel) and below (lower channel) the midline; -1 marks columns
+// without a bar.
+func (v *Visualizer) verticalExtents(channels [][]float64) (up, down []float64) {
+	midline := v.config.Height / 2
+	upper := channels[0]
+	lower := channels[len(channels)-1]
+
+	up = make([]float64, v.config.Width)
+	down = make([]float64, v.config.Width)
+	for col := range v.config.Width {
+		up[col], down[col] = -1, -1
+		band, ok := v.bandAt(col, len(upper))
+		if !ok {
+			continue
+		}
+
+		up[col] = v.barLevel(upper[band]) * float64(midline-1)
+		down[col] = v.barLevel(lower[band]) * float64(midline-1)
+	}
+	return up, down
+}
+
+func cellExtent(up, down float64, row, midline int) float64 {
+	if row > midline || (row == midline && down > up) {
+		return down
+	}
+	return up
+}
+
+var (
+	lowerBlocks = []string{"", "▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+	upperBlocks = []string{"", "▔", "▔", "▔", "▀", "▀", "▀", "█", "█"}
+)
+
+// verticalGlyph returns the glyph for the cell offset rows away from the
+// midline of a mirrored bar with the given extent, or "" for an empty cell.
+// With SubCell the cell just past the bar's end shows the fractional part
+// using eighth blocks; Unicode only has a few upper blocks, so cells below
+// the midline are rounded to the nearest one. SolidCenter fills the midline
+// cell of any bar above zero, which would otherwise stay blank until the bar
+// reaches a whole cell.
+func (v *Visualizer) verticalGlyph(offset int, extent float64, below bool) string {
+	height := int(extent)
+	if (height > 0 || v.config.SolidCenter && extent > 0) && offset <= height {
+		if v.subCell() {
+			return "█"
+		}
+		return v.barChar(offset, height+1)
+	}
+	if !v.subCell() || extent <= 0 {
+		return ""
+	}
+
+	partial := height + 1
+	if height == 0 {
+		partial = 0
+	}
+	if offset != partial {
+		return ""
+	}
+
+	eighths := int((extent - float64(height)) * 8)
+	if below && offset > 0 {
+		return upperBlocks[eighths]
+	}
+	return lowerBlocks[eighths]
+}
+
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// renderBraille packs a 2x4 dot grid into every cell, mirroring bars around
+// the middle dot row just like the block renderer, or raising them from the
+// bottom dot row in the ground layout.
+func (v *Visualizer) renderBraille(sb *strings.Builder, channels [][]float64) {
+	midline, midDot := v.brailleMidline()
+	ground := v.config.Layout == LayoutGround
+	up, down, solid := v.brailleExtents(channels)
+
+	for row := range v.config.Height {
+		color := ""
+		for col := range v.config.Width {
+			var dots rune
+			for dx := range 2 {
+				dotCol := col*2 + dx
+				for dy := range 4 {
+					dotRow := row*4 + dy
+					height := up[dotCol]
+					if dotRow > midDot || (dotRow == midDot && down[dotCol] > height) {
+						height = down[dotCol]
+					}
+					if ground {
+						if midDot-dotRow < height {
+							dots |= brailleDots[dx][dy]
+						}
+					} else if (height > 0 || solid[dotCol]) && abs(dotRow-midDot) <= height {
+						dots |= brailleDots[dx][dy]
+					}
+				}
+			}
+
+			if dots == 0 {
+				v.writeBlank(sb, &color)
+				continue
+			}
+			if v.config.Color {
+				if c := v.colorFor(abs(row-midline), midline-1, col, v.config.Width); c != color {
+					sb.WriteString(c)
+					color = c
+				}
+			}
+			sb.WriteRune(0x2800 + dots)
+		}
+		if v.config.Color {
+			sb.WriteString(colorReset)
+		}
+		sb.WriteByte('\n')
+	}
+}
+
+// brailleMidline returns the cell row and dot row Braille bars grow from:
+// the middle for the mirror layout and the bottom for the ground layout.
+func (v *Visualizer) brailleMidline() (row, dot int) {
+	if v.config.Layout == LayoutGround {
+		return v.config.Height - 1, v.config.Height*4 - 1
+	}
+	return v.config.Height / 2, v.config.Height * 4 / 2
+}
+
+// brailleExtents returns, per dot column, how many dots the bar reaches
+// above and below the middle dot row, or up from the bottom in the ground
+// layout where the channels are mixed; -1 marks columns without a bar.
+// solid marks mirrored bars that SolidCenter keeps on the middle row.
+func (v *Visualizer) brailleExtents(channels [][]float64) (up, down []int, solid []bool) {
+	_, midDot := v.brailleMidline()
+	upper := channels[0]
+	lower := channels[len(channels)-1]
+	ground := v.config.Layout == LayoutGround
+	if ground {
+		upper = mixChannels(channels)
+		lower = upper
+	}
+
+	dotCols := v.config.Width * 2
+	up = make([]int, dotCols)
+	down = make([]int, dotCols)
+	solid = make([]bool, dotCols)
+	for col := range dotCols {
+		up[col], down[col] = -1, -1
+		band, ok := v.bandAt(col, len(upper))
+		if !ok {
+			continue
+		}
+
+		if ground {
+			up[col] = v.barHeight(upper[band], midDot+1)
+			continue
+		}
+		up[col] = v.barHeight(upper[band], midDot-1)
+		down[col] = v.barHeight(lower[band], midDot-1)
+		solid[col] = v.config.SolidCenter && max(v.barLevel(upper[band]), v.barLevel(lower[band])) > 0
+	}
+	return up, down, solid
+}
+
+// renderHorizontal draws one band per row. Mono bars grow rightward from the
+// left edge across Width; stereo bars grow outward from the center, left
+// channel to the left and right channel to the right.
+func (v *Visualizer) renderHorizontal(sb *strings.Builder, channels [][]float64) {
+	left, right, origin, span := v.horizontalExtents(channels)
+
+	for row := range v.config.Height {
+		color := ""
+		for col := range v.config.Width {
+			level, length, maxLevel := col-origin, right[row], span
+			if col < origin {
+				level, length, maxLevel = origin-1-col, left[row], origin
+			}
+
+			if level < length {
+				if v.config.Color {
+					if c := v.colorFor(level, maxLevel-1, row, v.config.Height); c != color {
+						sb.WriteString(c)
+						color = c
+					}
+				}
+				sb.WriteString(v.barChar(level, length))
+			} else {
+				v.writeBlank(sb, &color)
+			}
+		}
+		if v.config.Color {
+			sb.WriteString(colorReset)
+		}
+		sb.WriteByte('\n')
+	}
+}
+
+// horizontalExtents returns, per row, how many cells the left and right bars
+// cover outward from origin, the column they start at, and span, the cells
+// available to the right bar. Mono input only has right bars; -1 marks rows
+// without a bar.
+func (v *Visualizer) horizontalExtents(channels [][]float64) (left, right []int, origin, span int) {
+	stereo := len(channels) > 1
+	origin, span = 0, v.config.Width
+	if stereo {
+		origin = v.config.Width / 2
+		span = v.config.Width - origin
+	}
+
+	left = make([]int, v.config.Height)
+	right = make([]int, v.config.Height)
+	for row := range v.config.Height {
+		left[row], right[row] = -1, -1
+		if band, ok := v.bandAt(row, len(channels[0])); ok {
+			right[row] = v.barHeight(channels[len(channels)-1][band], span)
+			if stereo {
+				left[row] = v.barHeight(channels[0][band], origin)
+			}
+		}
+	}
+	return left, right, origin, span
+}
+
+var axisFrequencies = []float64{60, 250, 1000, 4000, 16000}
+
+// showAxis reports whether the frequency axis applies: it labels columns, so
+// it is only drawn for vertical spectrum bars.
+func (v *Visualizer) showAxis() bool {
+	return v.config.ShowAxis && v.config.Mode == ModeSpectrum && v.config.Orientation != OrientationHorizontal
+}
+
+// axisLine places each of axisFrequencies within the displayed range under
+// its nearest column, skipping labels that would overlap the previous one or
+// run off the edge.
+func (v *Visualizer) axisLine() string {
+	width := v.config.Width
+	line := []byte(strings.Repeat(" ", width))
+	lowest, highest := v.columnFrequency(0), v.columnFrequency(width-1)
+
+	free := 0
+	for _, hz := range axisFrequencies {
+		if hz < lowest || hz > highest {
+			continue
+		}
+
+		best, dist := 0, math.Inf(1)
+		for col := range width {
+			if d := math.Abs(math.Log(v.columnFrequency(col) / hz)); d < dist {
+				best, dist = col, d
+			}
+		}
+
+		label := formatFrequency(hz)
+		if best >= free && best+len(label) <= width {
+			copy(line[best:], label)
+			free = best + len(label) + 1
+		}
+	}
+	return string(line)
+}
+
+func formatFrequency(hz float64) string {
+	if hz >= 1000 {
+		return strconv.FormatFloat(hz/1000, 'f', -1, 64) + "k"
+	}
+	return strconv.FormatFloat(hz, 'f', -1, 64)
+}
+
+// statusLine uses Config.StatusFunc when set, falling back to the default
+// text if it panics.
+func (v *Visualizer) statusLine() (line string) {
+	fps := fmt.Sprintf("%d FPS", v.config.FPS)
+	if v.config.FPS == UncappedFPS {
+		fps = "uncapped FPS"
+	}
+	def := fmt.Sprintf("Audio Visualizer | %dHz | %d samples | %s",
+		v.config.SampleRate, v.config.ChunkSize, fps)
+	if v.config.StatusFunc == nil {
+		return def
+	}
+
+	defer func() {
+		if recover() != nil {
+			line = def
+		}
+	}()
+
+	var elapsed time.Duration
+	if !v.startedAt.IsZero() {
+		elapsed = time.Since(v.startedAt)
+	}
+	return v.config.StatusFunc(Status{
+		Track:       v.track,
+		Elapsed:     elapsed,
+		Level:       v.level,
+		Peak:        v.peak,
+		Balance:     v.balance,
+		Correlation: v.correlation,
+		SampleRate:  v.config.SampleRate,
+		ChunkSize:   v.config.ChunkSize,
+		FPS:         v.config.FPS,
+	})
+}
+
+// bandAt maps a bar position (column, or row in horizontal mode) to a band,
+// reporting false for spacing gaps and positions past the last band. With
+// Bands set each band is stretched over a group of positions and BarSpacing
+// blanks the end of every group instead.
+func (v *Visualizer) bandAt(pos, bands int) (int, bool) {
+	if v.config.Bands > 0 {
+		positions := v.positionCount()
+		band := pos * v.config.Bands / positions
+		first := ceilDiv(band*positions, v.config.Bands)
+		width := ceilDiv((band+1)*positions, v.config.Bands) - first
+		if pos-first >= max(width-(v.config.BarSpacing-1), 1) {
+			return 0, false
+		}
+		return band, band < bands
+	}
+
+	if v.config.BarSpacing > 1 {
+		if pos%v.config.BarSpacing != 0 {
+			return 0, false
+		}
+		pos /= v.config.BarSpacing
+	}
+	return pos, pos < bands
+}
+
+// writeBlank writes an empty cell. A visible BlankChar is drawn uncolored,
+// so the background does not pick up the color of the bar before it.
+func (v *Visualizer) writeBlank(sb *strings.Builder, color *string) {
+	blank := v.config.BlankChar
+	if v.config.ASCIIFallback && !isASCII(blank) {
+		blank = " "
+	}
+	if *color != "" && blank != " " {
+		sb.WriteString(colorReset)
+		*color = ""
+	}
+	sb.WriteString(blank)
+}
+
+// barChar picks the glyph for the cell offset steps into a bar that fills
+// cells, walking HeightChars from the base to the tip.
+func (v *Visualizer) barChar(offset, cells int) string {
+	chars := v.config.HeightChars
+	if len(chars) == 0 {
+		return v.glyph(v.config.Char)
+	}
+	return v.glyph(chars[min(offset*len(chars)/max(cells, 1), len(chars)-1)])
+}
+
+// braille reports whether Braille cells are drawn; ASCIIFallback falls back
+// to blocks, and with them to one band per column.
+func (v *Visualizer) braille() bool {
+	return v.config.RenderStyle == StyleBraille && !v.config.ASCIIFallback
+}
+
+// subCell reports whether eighth blocks may be drawn.
+func (v *Visualizer) subCell() bool {
+	return v.config.SubCell && !v.config.ASCIIFallback
+}
+
+// glyph replaces a non-ASCII bar glyph with '#' under ASCIIFallback.
+func (v *Visualizer) glyph(g string) string {
+	if v.config.ASCIIFallback && !isASCII(g) {
+		return "#"
+	}
+	return g
+}
+
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+func (v *Visualizer) barHeight(value float64, maxHeight int) int {
+	return int(v.barLevel(value) * float64(maxHeight))
+}
+
+// barLevel maps a magnitude to the fraction of the available bar height,
+// shaped by Gamma.
+func (v *Visualizer) barLevel(value float64) float64 {
+	switch {
+	case v.config.PerFrameNormalize:
+		// renderFrame already scaled the frame to its own peak.
+	case v.config.AutoGain:
+		value *= autoGainTarget / max(v.gainPeak, autoGainMinPeak)
+	default:
+		value *= v.config.Amplify
+	}
+	if v.config.DBScale {
+		if value <= 0 {
+			return 0
+		}
+		value = 1 - 20*math.Log10(value)/v.config.DBFloor
+	}
+	value = math.Max(0, math.Min(value, 1))
+	if v.config.Gamma != 1 {
+		value = math.Pow(value, v.config.Gamma)
+	}
+	return value
+}
+
+// scopeLevel is barLevel for signed samples, keeping the sign.
+func (v *Visualizer) scopeLevel(value float64) float64 {
+	if value < 0 {
+		return -v.barLevel(-value)
+	}
+	return v.barLevel(value)
+}
+
+// colorFor picks the color of a cell level steps into a bar at position pos
+// of positions (columns, or rows when horizontal). ColorModeFrequency shades
+// by position so the palette runs from bass to treble across the display;
+// ClipColor overrides both for bands that just clipped.
+func (v *Visualizer) colorFor(level, maxLevel, pos, positions int) string {
+	if v.config.ClipColor != "" {
+		if band := v.columnBand(pos); band < len(v.clipped) && v.clipped[band] {
+			return v.config.ClipColor
+		}
+	}
+	if v.config.ColorMode == ColorModeFrequency {
+		return v.cellColor(pos, positions-1)
+	}
+	return v.cellColor(level, maxLevel)
+}
+
+func (v *Visualizer) cellColor(level, maxLevel int) string {
+	palette := v.config.Palette
+	if maxLevel <= 0 {
+		return palette[len(palette)-1]
+	}
+	idx := level * len(palette) / (maxLevel + 1)
+	return palette[min(idx, len(palette)-1)]
+}
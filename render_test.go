@@ -0,0 +1,135 @@
+package spectrum
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestNoiseFloorBlanksNoise renders near-silent noise amplified enough to
+// draw bars and expects NoiseFloor to gate it out.
+func TestNoiseFloorBlanksNoise(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float64, 4096)
+	for i := range samples {
+		samples[i] = (rng.Float64() - 0.5) * 0.004
+	}
+
+	render := func(floor float64) string {
+		v := New(Config{RawFrame: true, Width: 20, Height: 8, SmoothFactor: 1, Amplify: 500, NoiseFloor: floor})
+		for range 10 {
+			v.Update(samples)
+		}
+		return v.Render()
+	}
+
+	if frame := render(0); strings.TrimSpace(frame) == "" {
+		t.Fatal("noise renders blank without a floor; the test proves nothing")
+	}
+	if frame := render(0.01); strings.TrimSpace(frame) != "" {
+		t.Errorf("noise below NoiseFloor rendered bars:\n%s", frame)
+	}
+}
+
+// drawnHeights measures every bar in a RawFrame block or Braille frame the
+// way BarHeights reports it: the upper half of mirrored bars and the left
+// half of stereo horizontal ones.
+func drawnHeights(v *Visualizer, frame string) []int {
+	cfg := v.Config()
+	var grid [][]rune
+	for _, line := range strings.Split(frame, "\n")[:cfg.Height] {
+		grid = append(grid, []rune(line))
+	}
+	drawn := func(row, col int) bool { return grid[row][col] != ' ' }
+
+	var heights []int
+	switch {
+	case cfg.Orientation == OrientationHorizontal:
+		cols := cfg.Width
+		if cfg.Channels > 1 {
+			cols /= 2
+		}
+		for row := range cfg.Height {
+			n := 0
+			for col := range cols {
+				if drawn(row, col) {
+					n++
+				}
+			}
+			heights = append(heights, n)
+		}
+	case cfg.RenderStyle == StyleBraille:
+		rows := cfg.Height * 4
+		if cfg.Layout != LayoutGround {
+			rows /= 2
+		}
+		for dotCol := range cfg.Width * 2 {
+			n := 0
+			for dotRow := range rows {
+				dots := grid[dotRow/4][dotCol/2] - 0x2800
+				if dots >= 0 && dots&brailleDots[dotCol%2][dotRow%4] != 0 {
+					n++
+				}
+			}
+			heights = append(heights, n)
+		}
+	default:
+		rows := cfg.Height
+		if cfg.Layout != LayoutGround {
+			rows /= 2
+		}
+		for col := range cfg.Width {
+			n := 0
+			for row := range rows {
+				if drawn(row, col) {
+					n++
+				}
+			}
+			heights = append(heights, n)
+		}
+	}
+	return heights
+}
+
+// TestBarHeightsMatchRender draws noise in every layout and style and
+// expects BarHeights to measure the bars exactly as renderFrame drew them.
+func TestBarHeightsMatchRender(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float64, 4096)
+	for i := range samples {
+		// Ramp the noise up so the bars cover a range of heights.
+		samples[i] = (rng.Float64()*2 - 1) * float64(i) / float64(len(samples))
+	}
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"mirror", Config{}},
+		{"ground", Config{Layout: LayoutGround}},
+		{"braille mirror", Config{RenderStyle: StyleBraille}},
+		{"braille ground", Config{RenderStyle: StyleBraille, Layout: LayoutGround}},
+		{"horizontal", Config{Orientation: OrientationHorizontal}},
+		{"horizontal stereo", Config{Orientation: OrientationHorizontal, Channels: 2}},
+		{"spaced", Config{BarSpacing: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.RawFrame, cfg.Width, cfg.Height, cfg.SmoothFactor = true, 16, 8, 1
+			v := New(cfg)
+			v.Update(samples)
+
+			got := v.BarHeights()
+			want := drawnHeights(v, v.Render())
+			if !slices.Equal(got, want) {
+				t.Errorf("BarHeights() = %v, drawn %v", got, want)
+			}
+			if slices.Max(want) == 0 {
+				t.Error("no bars drawn; the test proves nothing")
+			}
+		})
+	}
+}
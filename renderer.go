@@ -0,0 +1,39 @@
+package spectrum
+
+import "io"
+
+// Renderer is a frame sink: set Config.Renderer and the stream hands it each
+// frame's values, one slice per channel with one value per band as
+// GetChannelWaveforms returns them, instead of writing text to Output. The
+// values stay per channel because the stereo layouts draw each channel on
+// its own side; average them for a mono frame as GetWaveform does.
+// DropFrames and SkipUnchanged apply as they do to Output. The stream stops
+// on the first error WriteFrame returns.
+type Renderer interface {
+	WriteFrame(channels [][]float64) error
+}
+
+// TerminalRenderer draws frames with vis's display settings, as the stream
+// does without a Renderer, including the clears that ForceClear and resizes
+// ask for.
+type TerminalRenderer struct {
+	vis *Visualizer
+	w   io.Writer
+}
+
+// NewTerminalRenderer returns a TerminalRenderer that writes to w.
+func NewTerminalRenderer(vis *Visualizer, w io.Writer) *TerminalRenderer {
+	return &TerminalRenderer{vis: vis, w: w}
+}
+
+func (t *TerminalRenderer) WriteFrame(channels [][]float64) error {
+	var out string
+	if t.vis.clear.Swap(false) {
+		out = t.vis.clearRegion()
+	}
+	t.vis.mu.RLock()
+	out += t.vis.renderFrame(channels)
+	t.vis.mu.RUnlock()
+	_, err := io.WriteString(t.w, out)
+	return err
+}
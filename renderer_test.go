@@ -0,0 +1,148 @@
+package spectrum
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stereoVisualizer returns a two-channel Visualizer fed a loud left and a
+// quiet right channel.
+func stereoVisualizer(cfg Config) *Visualizer {
+	cfg.Channels, cfg.Width, cfg.Height, cfg.SmoothFactor = 2, 20, 8, 1
+	v := New(cfg)
+	samples := make([]float64, 4096)
+	for i := 0; i < len(samples); i += 2 {
+		s := math.Sin(2 * math.Pi * 440 * float64(i/2) / 44100)
+		samples[i], samples[i+1] = 0.8*s, 0.05*s
+	}
+	v.Update(samples)
+	return v
+}
+
+func TestTerminalRendererMatchesRender(t *testing.T) {
+	v := stereoVisualizer(Config{RawFrame: true})
+	var buf bytes.Buffer
+	if err := NewTerminalRenderer(v, &buf).WriteFrame(v.GetChannelWaveforms()); err != nil {
+		t.Fatal(err)
+	}
+	want := v.Render()
+	if mixed := v.renderFrame([][]float64{v.GetWaveform()}); mixed == want {
+		t.Fatal("stereo renders like its mono mix; the test proves nothing")
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("TerminalRenderer drew\n%s\nwant Render's\n%s", got, want)
+	}
+}
+
+func TestTerminalRendererForceClear(t *testing.T) {
+	v := stereoVisualizer(Config{})
+	var buf bytes.Buffer
+	r := NewTerminalRenderer(v, &buf)
+
+	v.ForceClear()
+	for i, wantClear := range []bool{true, false} {
+		buf.Reset()
+		if err := r.WriteFrame(v.GetChannelWaveforms()); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.HasPrefix(buf.String(), v.clearRegion()); got != wantClear {
+			t.Errorf("frame %d starts with the clear = %v, want %v", i, got, wantClear)
+		}
+	}
+}
+
+// channelSpy records how many channels each frame carries.
+type channelSpy struct {
+	mu       sync.Mutex
+	channels []int
+}
+
+func (s *channelSpy) WriteFrame(channels [][]float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels = append(s.channels, len(channels))
+	return nil
+}
+
+func TestRendererGetsChannels(t *testing.T) {
+	out := &syncBuffer{}
+	spy := &channelSpy{}
+	v := New(Config{Output: out, Renderer: spy, Channels: 2})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_ = v.StartFromTone(ctx, 440)
+
+	spy.mu.Lock()
+	defer spy.mu.Unlock()
+	if len(spy.channels) == 0 {
+		t.Fatal("Renderer got no frames")
+	}
+	for i, n := range spy.channels {
+		if n != 2 {
+			t.Fatalf("frame %d has %d channels, want 2", i, n)
+		}
+	}
+	if out.buf.Len() != 0 {
+		t.Errorf("stream wrote %d bytes to Output with a Renderer set", out.buf.Len())
+	}
+}
+
+// countingRenderer counts frames, taking delay over each one.
+type countingRenderer struct {
+	mu     sync.Mutex
+	frames int
+	delay  time.Duration
+}
+
+func (r *countingRenderer) WriteFrame(channels [][]float64) error {
+	time.Sleep(r.delay)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames++
+	return nil
+}
+
+func (r *countingRenderer) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.frames
+}
+
+// TestRendererSkipUnchanged streams silence, where every frame is the same,
+// and expects SkipUnchanged to hand the Renderer only the first one.
+func TestRendererSkipUnchanged(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		r := &countingRenderer{}
+		v := New(Config{Renderer: r, SkipUnchanged: skip, LowLatency: true, ChunkSize: 1024})
+		silence := bytes.NewReader(make([]byte, 16*1024*2))
+		if err := v.startFromReader(context.Background(), silence, v.Config().pcm(), true); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.count(); skip && got != 1 || !skip && got < 2 {
+			t.Errorf("SkipUnchanged %v: Renderer got %d frames of silence", skip, got)
+		}
+	}
+}
+
+// TestRendererDropFrames feeds a slow Renderer and expects DropFrames to
+// drop frames instead of holding up the stream.
+func TestRendererDropFrames(t *testing.T) {
+	r := &countingRenderer{delay: 20 * time.Millisecond}
+	v := New(Config{Renderer: r, DropFrames: true, LowLatency: true, ChunkSize: 1024})
+	tone := io.LimitReader(NewToneReader(440, 44100, 1), 64*1024*2)
+	if err := v.startFromReader(context.Background(), tone, v.Config().pcm(), true); err != nil {
+		t.Fatal(err)
+	}
+	if dropped := v.Stats().Dropped; dropped == 0 {
+		t.Error("no frames dropped for a Renderer slower than the stream")
+	}
+	if got := r.count(); got >= 64 {
+		t.Errorf("Renderer got %d of 64 frames; want some dropped", got)
+	}
+}
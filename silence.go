@@ -0,0 +1,35 @@
+package spectrum
+
+import "time"
+
+// silenceResumeRatio is the hysteresis between going silent and resuming:
+// audio must come back this far above SilenceThreshold to count.
+const silenceResumeRatio = 2
+
+// silenceDetector tracks how long the level has stayed below the threshold.
+type silenceDetector struct {
+	quietSince time.Duration
+	quiet      bool
+	silent     bool
+}
+
+// update reports whether the stream just went silent or just resumed.
+func (s *silenceDetector) update(level float64, at time.Duration, threshold float64, duration time.Duration) (silenced, resumed bool) {
+	if level < threshold {
+		if !s.quiet {
+			s.quiet, s.quietSince = true, at
+		}
+		if !s.silent && at-s.quietSince >= duration {
+			s.silent = true
+			return true, false
+		}
+		return false, false
+	}
+
+	s.quiet = false
+	if s.silent && level >= threshold*silenceResumeRatio {
+		s.silent = false
+		return false, true
+	}
+	return false, false
+}
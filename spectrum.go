@@ -2,33 +2,194 @@ package spectrum
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
+	"math/cmplx"
+	"os"
 	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+type Mode string
+
+const (
+	ModeWaveform Mode = "waveform"
+	ModeSpectrum Mode = "spectrum"
+	// ModeScope plots the signal itself, one sample per column around the
+	// midline, like an oscilloscope.
+	ModeScope Mode = "scope"
+)
+
+type Window string
+
+const (
+	WindowNone     Window = "none"
+	WindowHann     Window = "hann"
+	WindowHamming  Window = "hamming"
+	WindowBlackman Window = "blackman"
+)
+
+type Weighting string
+
+const (
+	WeightingNone Weighting = "none"
+	WeightingA    Weighting = "a-weighting"
+)
+
+// Aggregation selects how ModeWaveform reduces the samples under a column
+// to one value.
+type Aggregation string
+
+const (
+	AggregationRMS  Aggregation = "rms"
+	AggregationPeak Aggregation = "peak"
+	AggregationMean Aggregation = "mean"
+)
+
+type Orientation string
+
+const (
+	OrientationVertical   Orientation = "vertical"
+	OrientationHorizontal Orientation = "horizontal"
+)
+
+type Layout string
+
+const (
+	LayoutMirror Layout = "mirror"
+	LayoutGround Layout = "ground"
+)
+
+type ColorMode string
+
+const (
+	ColorModeNone      ColorMode = "none"
+	ColorModeAmplitude ColorMode = "amplitude"
+	ColorModeFrequency ColorMode = "frequency"
+)
+
+type RenderStyle string
+
+const (
+	StyleBlock   RenderStyle = "block"
+	StyleBraille RenderStyle = "braille"
+)
+
 type Config struct {
-	Width        int
-	Height       int
-	SampleRate   int
-	ChunkSize    int
-	FPS          int
-	SmoothFactor float64
-	Char         string
-	BarSpacing   int
-	Amplify      float64
-	ShowStatus   bool
+	Mode              Mode
+	Width             int
+	Height            int
+	Bands             int
+	SampleRate        int
+	ChunkSize         int
+	FramesPerRender   int
+	FFTSize           int
+	FFTOverlap        float64
+	ReadBufferChunks  int
+	LowLatency        bool
+	RenderTicker      bool
+	FPS               int
+	SmoothFactor      float64
+	SpectralSmooth    int
+	AttackFactor      float64
+	ReleaseFactor     float64
+	StallDecay        float64
+	PerFrameSmoothing bool
+	Char              string
+	BlankChar         string
+	HeightChars       []string
+	BarSpacing        int
+	Amplify           float64
+	ShowStatus        bool
+	ShowAxis          bool
+	ShowBalance       bool
+	LogScale          bool
+	Window            Window
+	Weighting         Weighting
+	Aggregation       Aggregation
+	BandGains         []float64
+	Color             bool
+	ColorMode         ColorMode
+	Palette           []string
+	ClipColor         string
+	Channels          int
+	MixWeights        []float64
+	AudioFilter       string
+	DBScale           bool
+	DBFloor           float64
+	Gamma             float64
+	AutoGain          bool
+	PerFrameNormalize bool
+	NoiseFloor        float64
+	Orientation       Orientation
+	Layout            Layout
+	SolidCenter       bool
+	SubCell           bool
+	RenderStyle       RenderStyle
+	ASCIIFallback     bool
+	AutoSize          bool
+	StatusFunc        func(Status) string
+	Output            io.Writer
+	Renderer          Renderer
+	OriginRow         int
+	OriginCol         int
+	RawFrame          bool
+	DropFrames        bool
+	SkipUnchanged     bool
+	SampleFormat      SampleFormat
+	Endianness        Endianness
+	FFmpegPath        string
+	FFprobePath       string
+	FFmpegInputArgs   []string
+	CommandFunc       func(ctx context.Context, streamURL string) *exec.Cmd
+	Logger            *slog.Logger
+
+	FiniteSource      bool
+	ReconnectAttempts int
+	ReconnectDelay    time.Duration
+	ShutdownGrace     time.Duration
+	ICYMetadata       bool
+	TrackSeparators   []string
+	// TrackRegex extracts the track from the raw title through its named
+	// groups "artist" and "title". Titles it does not match are split on
+	// TrackSeparators.
+	TrackRegex *regexp.Regexp
+
+	// TrackRefreshInterval is how long a FetchTrack result is reused
+	// before ffprobe runs again. Negative values probe on every call.
+	TrackRefreshInterval time.Duration
+
+	OnBeat          func()
+	BeatSensitivity float64
+	BeatMinInterval time.Duration
+
+	OnSilence        func()
+	OnResume         func()
+	SilenceThreshold float64
+	SilenceDuration  time.Duration
+
+	// Tap receives every decoded chunk, interleaved when Channels is 2, before
+	// it is visualized. It runs on the stream goroutine, so it must return
+	// quickly or hand the work off; the slice is reused after it returns.
+	Tap func([]float64)
 }
 
 func DefaultConfig() Config {
 	return Config{
+		Mode:         ModeWaveform,
 		Width:        60,
 		Height:       12,
 		SampleRate:   44100,
@@ -36,305 +197,2210 @@ func DefaultConfig() Config {
 		FPS:          30,
 		SmoothFactor: 0.9,
 		Char:         "|",
+		BlankChar:    " ",
 		BarSpacing:   1,
 		Amplify:      2.5,
 		ShowStatus:   true,
+		Window:       WindowHann,
+		Weighting:    WeightingNone,
+		Aggregation:  AggregationRMS,
+		DBFloor:      -60,
+		Gamma:        1,
+		Orientation:  OrientationVertical,
+		Layout:       LayoutMirror,
+		RenderStyle:  StyleBlock,
+		OriginRow:    2,
+		Output:       os.Stdout,
+		SampleFormat: FormatS16LE,
+		Endianness:   EndianLittle,
+		FFmpegPath:   "ffmpeg",
+		FFprobePath:  "ffprobe",
+
+		ReconnectDelay: 2 * time.Second,
+		ShutdownGrace:  2 * time.Second,
+
+		TrackSeparators:      DefaultTrackSeparators,
+		TrackRefreshInterval: 10 * time.Second,
+
+		BeatSensitivity: 1.5,
+		BeatMinInterval: 250 * time.Millisecond,
+
+		SilenceThreshold: 0.01,
+		SilenceDuration:  2 * time.Second,
+	}
+}
+
+// withDefaults fills zero-valued fields with their defaults.
+func (c Config) withDefaults() Config {
+	if c.Mode == "" {
+		c.Mode = ModeWaveform
+	}
+	if c.Width == 0 {
+		c.Width = 60
+	}
+	if c.Height == 0 {
+		c.Height = 12
+	}
+	if c.SampleRate == 0 {
+		c.SampleRate = 44100
+	}
+	if c.ChunkSize == 0 {
+		c.ChunkSize = 1024
+		if c.LowLatency {
+			c.ChunkSize = lowLatencyChunkSize
+		}
+	}
+	if c.FramesPerRender == 0 {
+		c.FramesPerRender = 1
+	}
+	if c.ReadBufferChunks == 0 {
+		c.ReadBufferChunks = 2
+		if c.LowLatency {
+			c.ReadBufferChunks = 1
+		}
+	}
+	if c.FPS == 0 {
+		c.FPS = 30
+	}
+	if c.Char == "" {
+		c.Char = "|"
+	}
+	if c.BlankChar == "" {
+		c.BlankChar = " "
+	}
+	if c.BarSpacing == 0 {
+		c.BarSpacing = 1
+	}
+	if c.Amplify == 0 {
+		c.Amplify = 2.5
+	}
+	if c.Window == "" {
+		c.Window = WindowHann
+	}
+	if c.Weighting == "" {
+		c.Weighting = WeightingNone
+	}
+	if c.Aggregation == "" {
+		c.Aggregation = AggregationRMS
+	}
+	// Color predates ColorMode and selects amplitude coloring; setting a
+	// ColorMode other than none turns Color on.
+	if c.ColorMode == "" {
+		c.ColorMode = ColorModeNone
+		if c.Color {
+			c.ColorMode = ColorModeAmplitude
+		}
+	}
+	c.Color = c.ColorMode != ColorModeNone
+	if len(c.Palette) == 0 {
+		c.Palette = DefaultPalette
+	}
+	if c.Channels == 0 {
+		c.Channels = 1
+	}
+	if c.DBFloor == 0 {
+		c.DBFloor = -60
+	}
+	if c.Gamma == 0 {
+		c.Gamma = 1
+	}
+	if c.Orientation == "" {
+		c.Orientation = OrientationVertical
 	}
+	if c.Layout == "" {
+		c.Layout = LayoutMirror
+	}
+	if c.RenderStyle == "" {
+		c.RenderStyle = StyleBlock
+	}
+	if c.OriginRow == 0 {
+		c.OriginRow = 2
+	}
+	if c.Output == nil {
+		c.Output = os.Stdout
+	}
+	if c.SampleFormat == "" {
+		c.SampleFormat = FormatS16LE
+	}
+	if c.Endianness == "" {
+		c.Endianness = EndianLittle
+	}
+	if c.FFmpegPath == "" {
+		c.FFmpegPath = "ffmpeg"
+	}
+	if c.FFprobePath == "" {
+		c.FFprobePath = "ffprobe"
+	}
+	if c.Logger == nil {
+		c.Logger = slog.New(discardHandler{})
+	}
+	if c.ReconnectDelay == 0 {
+		c.ReconnectDelay = 2 * time.Second
+	}
+	if c.ShutdownGrace == 0 {
+		c.ShutdownGrace = 2 * time.Second
+	}
+	if len(c.TrackSeparators) == 0 {
+		c.TrackSeparators = DefaultTrackSeparators
+	}
+	if c.TrackRefreshInterval == 0 {
+		c.TrackRefreshInterval = 10 * time.Second
+	}
+	if c.BeatSensitivity == 0 {
+		c.BeatSensitivity = 1.5
+	}
+	if c.BeatMinInterval == 0 {
+		c.BeatMinInterval = 250 * time.Millisecond
+	}
+	if c.SilenceThreshold == 0 {
+		c.SilenceThreshold = 0.01
+	}
+	if c.SilenceDuration == 0 {
+		c.SilenceDuration = 2 * time.Second
+	}
+	return c
+}
+
+// setLive copies the settings that may change while streaming. The stream
+// goroutine reads every other field without locking, so they must not be
+// written until it stops.
+func (c *Config) setLive(n Config) {
+	c.Mode = n.Mode
+	if !c.AutoSize {
+		c.Width, c.Height = n.Width, n.Height
+	}
+	c.Bands = n.Bands
+	c.SmoothFactor = n.SmoothFactor
+	c.SpectralSmooth = n.SpectralSmooth
+	c.FFTOverlap = n.FFTOverlap
+	c.AttackFactor = n.AttackFactor
+	c.ReleaseFactor = n.ReleaseFactor
+	c.StallDecay = n.StallDecay
+	c.PerFrameSmoothing = n.PerFrameSmoothing
+	c.Char = n.Char
+	c.BlankChar = n.BlankChar
+	c.HeightChars = n.HeightChars
+	c.BarSpacing = n.BarSpacing
+	c.Amplify = n.Amplify
+	c.ShowStatus = n.ShowStatus
+	c.ShowAxis = n.ShowAxis
+	c.ShowBalance = n.ShowBalance
+	c.LogScale = n.LogScale
+	c.Window = n.Window
+	c.Weighting = n.Weighting
+	c.Aggregation = n.Aggregation
+	c.BandGains = n.BandGains
+	c.Color = n.Color
+	c.ColorMode = n.ColorMode
+	c.Palette = n.Palette
+	c.ClipColor = n.ClipColor
+	c.DBScale = n.DBScale
+	c.DBFloor = n.DBFloor
+	c.Gamma = n.Gamma
+	c.AutoGain = n.AutoGain
+	c.PerFrameNormalize = n.PerFrameNormalize
+	c.NoiseFloor = n.NoiseFloor
+	c.Orientation = n.Orientation
+	c.Layout = n.Layout
+	c.SolidCenter = n.SolidCenter
+	c.SubCell = n.SubCell
+	c.RenderStyle = n.RenderStyle
+	c.ASCIIFallback = n.ASCIIFallback
+	c.StatusFunc = n.StatusFunc
+	c.OriginRow = n.OriginRow
+	c.OriginCol = n.OriginCol
+	c.RawFrame = n.RawFrame
+	c.OnBeat = n.OnBeat
+	c.BeatSensitivity = n.BeatSensitivity
+	c.BeatMinInterval = n.BeatMinInterval
+	c.OnSilence = n.OnSilence
+	c.OnResume = n.OnResume
+	c.SilenceThreshold = n.SilenceThreshold
+	c.SilenceDuration = n.SilenceDuration
+	c.Tap = n.Tap
+}
+
+// readBufferSize is the read-ahead between the source and the analysis in
+// bytes, ReadBufferChunks chunks of interleaved samples in format.
+func (c Config) readBufferSize(format SampleFormat) int {
+	return c.ReadBufferChunks * c.ChunkSize * c.Channels * format.bytesPerSample()
+}
+
+// pcm is the sample encoding the Config describes.
+func (c Config) pcm() pcmFormat {
+	return pcmFormat{format: c.SampleFormat, endian: c.Endianness}
+}
+
+// UncappedFPS as Config.FPS renders a frame for every chunk as soon as it is
+// decoded, without pacing. It costs far more CPU than a terminal can show
+// and is meant for file, pipe and network sinks.
+const UncappedFPS = -1
+
+// uncappedTickFPS is the tick rate of ticker-driven work, such as stall
+// decay and exports, under UncappedFPS.
+const uncappedTickFPS = 30
+
+// frameInterval is the pacing between frames, 0 for UncappedFPS.
+func (c Config) frameInterval() time.Duration {
+	if c.FPS == UncappedFPS {
+		return 0
+	}
+	return time.Second / time.Duration(c.FPS)
+}
+
+// tickInterval is frameInterval for tickers, which need a positive period.
+func (c Config) tickInterval() time.Duration {
+	if c.FPS == UncappedFPS {
+		return time.Second / uncappedTickFPS
+	}
+	return c.frameInterval()
+}
+
+// frameSamples is the number of samples per channel analysed for each
+// rendered frame. A spectrum with an FFTSize beyond the latest chunks keeps
+// enough older audio to fill one transform.
+func (c Config) frameSamples() int {
+	n := c.ChunkSize * c.FramesPerRender
+	if c.Mode == ModeSpectrum {
+		n = max(n, c.FFTSize)
+	}
+	return n
+}
+
+// fftLength returns the transform size and the number of samples windowed
+// into each transform. Without an FFTSize the whole frame is transformed
+// once, zero-padded to a power of two.
+func (c Config) fftLength() (size, window int) {
+	if c.FFTSize > 0 {
+		return c.FFTSize, c.FFTSize
+	}
+	return nextPowerOfTwo(c.frameSamples()), c.frameSamples()
+}
+
+// Validate reports the first setting that cannot produce a working display.
+// Zero values are accepted since they select the defaults.
+func (c Config) Validate() error {
+	c = c.withDefaults()
+
+	switch c.Mode {
+	case ModeWaveform, ModeSpectrum, ModeScope:
+	default:
+		return fmt.Errorf("unknown mode %q", c.Mode)
+	}
+	switch c.Window {
+	case WindowNone, WindowHann, WindowHamming, WindowBlackman:
+	default:
+		return fmt.Errorf("unknown window %q", c.Window)
+	}
+	switch c.Weighting {
+	case WeightingNone, WeightingA:
+	default:
+		return fmt.Errorf("unknown weighting %q", c.Weighting)
+	}
+	switch c.Aggregation {
+	case AggregationRMS, AggregationPeak, AggregationMean:
+	default:
+		return fmt.Errorf("unknown aggregation %q", c.Aggregation)
+	}
+	for _, g := range c.BandGains {
+		if g < 0 || math.IsNaN(g) || math.IsInf(g, 0) {
+			return fmt.Errorf("band gain %g must be a finite, non-negative number", g)
+		}
+	}
+	switch c.Orientation {
+	case OrientationVertical, OrientationHorizontal:
+	default:
+		return fmt.Errorf("unknown orientation %q", c.Orientation)
+	}
+	switch c.Layout {
+	case LayoutMirror, LayoutGround:
+	default:
+		return fmt.Errorf("unknown layout %q", c.Layout)
+	}
+	switch c.ColorMode {
+	case ColorModeNone, ColorModeAmplitude, ColorModeFrequency:
+	default:
+		return fmt.Errorf("unknown color mode %q", c.ColorMode)
+	}
+	switch c.RenderStyle {
+	case StyleBlock, StyleBraille:
+	default:
+		return fmt.Errorf("unknown render style %q", c.RenderStyle)
+	}
+	switch c.SampleFormat {
+	case FormatS16LE, FormatS24LE, FormatS32LE, FormatF32LE:
+	default:
+		return fmt.Errorf("unknown sample format %q", c.SampleFormat)
+	}
+	switch c.Endianness {
+	case EndianLittle, EndianBig:
+	default:
+		return fmt.Errorf("unknown endianness %q", c.Endianness)
+	}
+
+	switch {
+	case c.Width < 1:
+		return fmt.Errorf("width must be positive, got %d", c.Width)
+	case c.Height < 1:
+		return fmt.Errorf("height must be positive, got %d", c.Height)
+	case c.SampleRate < 1:
+		return fmt.Errorf("sample rate must be positive, got %d", c.SampleRate)
+	case c.ChunkSize < 1:
+		return fmt.Errorf("chunk size must be positive, got %d", c.ChunkSize)
+	case c.FramesPerRender < 1:
+		return fmt.Errorf("frames per render must be positive, got %d", c.FramesPerRender)
+	case c.FFTSize < 0 || c.FFTSize == 1 || c.FFTSize&(c.FFTSize-1) != 0:
+		return fmt.Errorf("fft size must be a power of two, got %d", c.FFTSize)
+	case c.FFTOverlap < 0 || c.FFTOverlap >= 1 || math.IsNaN(c.FFTOverlap):
+		return fmt.Errorf("fft overlap must be within [0, 1), got %g", c.FFTOverlap)
+	case c.ReadBufferChunks < 1:
+		return fmt.Errorf("read buffer chunks must be positive, got %d", c.ReadBufferChunks)
+	case c.FPS < 1 && c.FPS != UncappedFPS:
+		return fmt.Errorf("fps must be positive or UncappedFPS, got %d", c.FPS)
+	case c.FPS == UncappedFPS && c.RenderTicker:
+		return errors.New("render ticker needs a capped fps")
+	case c.SpectralSmooth < 0:
+		return fmt.Errorf("spectral smoothing must not be negative, got %d", c.SpectralSmooth)
+	case c.Bands < 0:
+		return fmt.Errorf("bands must not be negative, got %d", c.Bands)
+	case c.Channels < 1:
+		return fmt.Errorf("channels must be positive, got %d", c.Channels)
+	case c.BarSpacing < 1:
+		return fmt.Errorf("bar spacing must be positive, got %d", c.BarSpacing)
+	case c.DBFloor >= 0:
+		return fmt.Errorf("dB floor must be negative, got %g", c.DBFloor)
+	case c.OriginRow < 1 || c.OriginCol < 0:
+		return fmt.Errorf("origin %d;%d is off the screen", c.OriginRow, c.OriginCol)
+	case c.Gamma < 0 || math.IsInf(c.Gamma, 0) || math.IsNaN(c.Gamma):
+		return fmt.Errorf("gamma must be positive, got %g", c.Gamma)
+	case c.ReconnectAttempts < 0:
+		return fmt.Errorf("reconnect attempts must not be negative, got %d", c.ReconnectAttempts)
+	}
+
+	// Every cell is assumed to be one column wide, so a wider or zero-width
+	// glyph would shift everything after it.
+	if displayWidth(c.Char) != 1 {
+		return fmt.Errorf("char %q must be one column wide", c.Char)
+	}
+	if displayWidth(c.BlankChar) != 1 {
+		return fmt.Errorf("blank char %q must be one column wide", c.BlankChar)
+	}
+	for _, char := range c.HeightChars {
+		if displayWidth(char) != 1 {
+			return fmt.Errorf("height char %q must be one column wide", char)
+		}
+	}
+
+	if c.TrackRegex != nil && c.TrackRegex.SubexpIndex("artist") < 0 && c.TrackRegex.SubexpIndex("title") < 0 {
+		return fmt.Errorf("track regex %q has no artist or title group", c.TrackRegex)
+	}
+
+	for _, arg := range c.FFmpegInputArgs {
+		if arg == "-i" {
+			return errors.New("ffmpeg input args must not contain -i; the input is added by the visualizer")
+		}
+	}
+
+	if len(c.MixWeights) > 0 {
+		if c.AudioFilter != "" {
+			return errors.New("mix weights and an audio filter cannot be combined")
+		}
+		if c.Channels != 1 {
+			return fmt.Errorf("mix weights need a mono output, got %d channels", c.Channels)
+		}
+		silent := true
+		for _, w := range c.MixWeights {
+			if math.IsNaN(w) || math.IsInf(w, 0) {
+				return fmt.Errorf("mix weight %g is not finite", w)
+			}
+			silent = silent && w == 0
+		}
+		if silent {
+			return errors.New("mix weights are all zero")
+		}
+	}
+
+	factors := []struct {
+		name  string
+		value float64
+	}{
+		{"smooth factor", c.SmoothFactor},
+		{"attack factor", c.AttackFactor},
+		{"release factor", c.ReleaseFactor},
+		{"stall decay", c.StallDecay},
+		{"noise floor", c.NoiseFloor},
+	}
+	for _, f := range factors {
+		if f.value < 0 || f.value > 1 {
+			return fmt.Errorf("%s must be within [0, 1], got %g", f.name, f.value)
+		}
+	}
+	return nil
+}
+
+const (
+	autoGainTarget   = 0.9
+	autoGainHalfLife = time.Second
+	autoGainMinPeak  = 1e-3
+)
+
+// lowLatencyChunkSize is the LowLatency default: 256 samples is 5.8 ms of
+// audio at 44.1 kHz, against 23.2 ms for the usual 1024.
+const lowLatencyChunkSize = 256
+
+const (
+	clipLevel = 0.999
+	clipHold  = time.Second
+)
+
+const (
+	eofRetryLimit = 20
+	eofBackoffMin = 10 * time.Millisecond
+	eofBackoffMax = 500 * time.Millisecond
+)
+
+const (
+	trackPollInterval = 3 * time.Second
+	probeTimeout      = 5 * time.Second
+)
+
+// sampleRateTolerance is how far, relative to SampleRate, a probed rate may
+// be off before it is reported.
+const sampleRateTolerance = 0.01
+
+const (
+	smoothingReference = time.Second / 30
+	maxSmoothingStep   = 250 * time.Millisecond
+)
+
+var ErrStreamEnded = errors.New("stream ended")
+
+// ErrAlreadyRunning is returned by the Start methods while another stream is
+// still running on the same Visualizer.
+var ErrAlreadyRunning = errors.New("visualizer is already running")
+
+type Status struct {
+	Track       TrackInfo
+	Elapsed     time.Duration
+	Level       float64
+	Peak        float64
+	Balance     float64
+	Correlation float64
+	SampleRate  int
+	ChunkSize   int
+	FPS         int
 }
 
 type TrackInfo struct {
-	Title  string
-	Artist string
-	Raw    string
+	Title     string `json:"title"`
+	Artist    string `json:"artist"`
+	Raw       string `json:"raw"`
+	Separator string `json:"separator"`
 }
 
+var DefaultTrackSeparators = []string{" - ", " – ", " — ", " | "}
+
 type Visualizer struct {
-	config    Config
-	waveform  []float64
-	smoothed  []float64
-	track     TrackInfo
-	streamURL string
-	mu        sync.RWMutex
-	cancel    context.CancelFunc
-	running   bool
+	config     Config
+	waveform   [][]float64
+	smoothed   [][]float64
+	fftBuf     []complex128
+	mags       []float64
+	window     []float64
+	winScale   float64
+	gains      []float64
+	track      TrackInfo
+	fetchedAt  time.Time
+	streamURL  string
+	mu         sync.RWMutex
+	running    atomic.Bool
+	redraw     atomic.Bool
+	clear      atomic.Bool
+	paused     atomic.Bool
+	icy        atomic.Bool
+	chunks     uint64
+	gainPeak   float64
+	level      float64
+	peak       float64
+	beats      *beatDetector
+	silence    silenceDetector
+	recorder   *frameRing
+	startedAt  time.Time
+	lastUpdate time.Time
+	lastClip   time.Duration
+	lastErr    error
+	clipped    []bool
+	stats      frameStats
+
+	balance     float64
+	correlation float64
+
+	runMu    sync.Mutex
+	cancel   context.CancelFunc
+	runDone  chan struct{}
+	stopping bool
+
+	subMu     sync.Mutex
+	frameSubs map[chan string]struct{}
+	trackSubs map[chan TrackInfo]struct{}
+	waveSubs  map[chan []float64]struct{}
+	eventSubs map[chan Event]context.CancelFunc
+}
+
+// NewWithError is like New but rejects configurations that Validate
+// reports as invalid.
+func NewWithError(cfg Config) (*Visualizer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return New(cfg), nil
 }
 
 func New(cfg Config) *Visualizer {
-	if cfg.Width == 0 {
-		cfg.Width = 60
+	v := &Visualizer{config: cfg.withDefaults(), correlation: 1}
+	v.configure()
+	if v.config.AutoSize {
+		v.fitTerminal()
+	}
+	return v
+}
+
+// configure sizes the analysis state for the current config, resampling the
+// smoothed values when only the band count changed. Callers hold mu.
+func (v *Visualizer) configure() {
+	v.redraw.Store(true)
+	cfg := v.config
+	bands := v.bandCount()
+	if len(v.smoothed) != cfg.Channels {
+		v.smoothed = makeChannels(cfg.Channels, bands)
+		v.waveform = makeChannels(cfg.Channels, bands)
+	}
+	for c := range v.smoothed {
+		if len(v.smoothed[c]) != bands {
+			v.smoothed[c] = resample(v.smoothed[c], bands)
+			v.waveform[c] = make([]float64, bands)
+		}
+	}
+
+	chunksPerSecond := cfg.SampleRate / cfg.ChunkSize
+	if v.beats == nil || len(v.beats.history) != max(chunksPerSecond, 1) {
+		v.beats = newBeatDetector(chunksPerSecond)
+	}
+
+	v.fftBuf, v.mags, v.window, v.gains = nil, nil, nil, nil
+	if cfg.Mode == ModeSpectrum {
+		n, length := cfg.fftLength()
+		v.fftBuf = make([]complex128, n)
+		v.mags = make([]float64, n/2+1)
+		v.window = windowCoefficients(cfg.Window, length)
+		sum := 0.0
+		for _, w := range v.window {
+			sum += w
+		}
+		v.winScale = 2.0 / sum
+		v.gains = v.bandGains(bands)
+	}
+}
+
+// Config returns a copy of the current configuration with defaults filled.
+func (v *Visualizer) Config() Config {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.config
+}
+
+// SetConfig replaces the configuration after validating it. While running,
+// only display and analysis settings change; the stream settings (rates,
+// chunking, channels, format, FPS, output and source options) keep their
+// current values until the next start.
+func (v *Visualizer) SetConfig(cfg Config) error {
+	return v.modifyConfig(func(c *Config) { *c = cfg })
+}
+
+func (v *Visualizer) SetAmplify(amplify float64) error {
+	return v.modifyConfig(func(c *Config) { c.Amplify = amplify })
+}
+
+func (v *Visualizer) SetSmoothFactor(factor float64) error {
+	return v.modifyConfig(func(c *Config) { c.SmoothFactor = factor })
+}
+
+func (v *Visualizer) SetChar(char string) error {
+	return v.modifyConfig(func(c *Config) { c.Char = char })
+}
+
+// SetColor switches between amplitude coloring and no color.
+func (v *Visualizer) SetColor(on bool) error {
+	return v.modifyConfig(func(c *Config) {
+		c.Color = on
+		c.ColorMode = ""
+	})
+}
+
+func (v *Visualizer) modifyConfig(modify func(*Config)) error {
+	// runMu keeps start from flipping running between the check below and
+	// the write, which would hand a starting stream a replaced config.
+	v.runMu.Lock()
+	defer v.runMu.Unlock()
+	v.mu.Lock()
+	cfg := v.config
+	modify(&cfg)
+	if err := cfg.Validate(); err != nil {
+		v.mu.Unlock()
+		return err
+	}
+	cfg = cfg.withDefaults()
+
+	running := v.running.Load()
+	if running {
+		v.config.setLive(cfg)
+	} else {
+		v.config = cfg
+	}
+	v.configure()
+	v.mu.Unlock()
+
+	if !running && cfg.AutoSize {
+		v.fitTerminal()
+	}
+	return nil
+}
+
+func (v *Visualizer) StartFromURL(ctx context.Context, streamURL string) error {
+	ctx, done, err := v.start(ctx, "url", streamURL)
+	if err != nil {
+		return err
+	}
+	defer done()
+	go v.checkSource(ctx, streamURL)
+
+	// A custom command reads the URL itself, so there is no ICY stream to
+	// split.
+	cfg := v.Config()
+	if cfg.CommandFunc != nil {
+		return v.superviseFFmpeg(ctx, func(ctx context.Context) *exec.Cmd {
+			return cfg.CommandFunc(ctx, streamURL)
+		}, nil)
+	}
+	if cfg.ICYMetadata && strings.HasPrefix(streamURL, "http") {
+		v.icy.Store(true)
+		defer v.icy.Store(false)
+		return v.superviseFFmpeg(ctx, v.ffmpegCommand([]string{"-i", "pipe:0"}), func(ctx context.Context) (io.ReadCloser, error) {
+			return openICY(ctx, streamURL, v.setStreamTitle)
+		})
+	}
+	return v.superviseFFmpeg(ctx, v.ffmpegCommand([]string{"-i", streamURL}), nil)
+}
+
+// StartFromDevice visualizes the default audio input (microphone or line-in)
+// through ffmpeg's platform capture device:
+//
+//	linux:   -f pulse -i <spec>        (default "default"; "alsa:<dev>" uses ALSA)
+//	darwin:  -f avfoundation -i <spec> (default ":0")
+//	windows: -f dshow -i audio=<spec>  (spec is required)
+func (v *Visualizer) StartFromDevice(ctx context.Context, deviceSpec string) error {
+	input, err := deviceInput(runtime.GOOS, deviceSpec)
+	if err != nil {
+		return err
+	}
+
+	ctx, done, err := v.start(ctx, "device", deviceSpec)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return v.superviseFFmpeg(ctx, v.ffmpegCommand(input), nil)
+}
+
+func deviceInput(goos, spec string) ([]string, error) {
+	switch goos {
+	case "linux":
+		if dev, ok := strings.CutPrefix(spec, "alsa:"); ok {
+			return []string{"-f", "alsa", "-i", dev}, nil
+		}
+		if spec == "" {
+			spec = "default"
+		}
+		return []string{"-f", "pulse", "-i", spec}, nil
+	case "darwin":
+		if spec == "" {
+			spec = ":0"
+		}
+		return []string{"-f", "avfoundation", "-i", spec}, nil
+	case "windows":
+		if spec == "" {
+			return nil, errors.New("a DirectShow audio device name is required on windows")
+		}
+		return []string{"-f", "dshow", "-i", "audio=" + spec}, nil
+	default:
+		return nil, fmt.Errorf("audio capture is not supported on %s", goos)
+	}
+}
+
+// superviseFFmpeg runs the command until the context ends, restarting it
+// after failures as configured. When source is set, each run feeds its
+// output to the command's stdin.
+func (v *Visualizer) superviseFFmpeg(ctx context.Context, command func(context.Context) *exec.Cmd, source func(context.Context) (io.ReadCloser, error)) error {
+	cfg := v.Config()
+	attempts := 0
+	for {
+		chunks := v.chunkCount()
+		err := v.runFFmpeg(ctx, command, source)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		v.setLastError(err)
+		if errors.Is(err, ErrFFmpegNotFound) {
+			return err
+		}
+
+		if v.chunkCount() > chunks {
+			attempts = 0
+		}
+		if attempts >= cfg.ReconnectAttempts {
+			return err
+		}
+		attempts++
+		v.stats.reconnect()
+		cfg.Logger.Warn("ffmpeg stopped, reconnecting",
+			"attempt", attempts, "max", cfg.ReconnectAttempts, "delay", cfg.ReconnectDelay, "err", err)
+
+		if err := sleepContext(ctx, cfg.ReconnectDelay); err != nil {
+			return err
+		}
+	}
+}
+
+// ffmpegCommand returns a builder for the built-in ffmpeg invocation that
+// decodes input to the configured PCM format on stdout.
+func (v *Visualizer) ffmpegCommand(input []string) func(context.Context) *exec.Cmd {
+	cfg := v.Config()
+	return func(ctx context.Context) *exec.Cmd {
+		return exec.CommandContext(ctx, cfg.FFmpegPath, cfg.ffmpegArgs(input)...)
+	}
+}
+
+func (c Config) ffmpegArgs(input []string) []string {
+	args := []string{
+		"-probesize", "32k",
+		"-analyzeduration", "0",
+		"-fflags", "nobuffer",
+		"-flags", "low_delay",
+	}
+	args = append(args, c.FFmpegInputArgs...)
+	args = append(args, input...)
+	switch {
+	case len(c.MixWeights) > 0:
+		args = append(args, "-af", panFilter(c.MixWeights))
+	case c.AudioFilter != "":
+		args = append(args, "-af", c.AudioFilter)
+	}
+	args = append(args,
+		"-ac", strconv.Itoa(c.Channels),
+		"-ar", strconv.Itoa(c.SampleRate),
+		"-f", c.SampleFormat.ffmpegFormat(c.Endianness),
+		"-acodec", c.SampleFormat.codec(c.Endianness),
+		"-vn",
+		"-",
+	)
+	return args
+}
+
+// runFFmpeg runs one command built by command and streams its stdout. A
+// CommandFunc command keeps any Cancel, WaitDelay and Stderr it sets.
+func (v *Visualizer) runFFmpeg(ctx context.Context, command func(context.Context) *exec.Cmd, source func(context.Context) (io.ReadCloser, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cfg := v.Config()
+
+	var stdin io.ReadCloser
+	if source != nil {
+		var err error
+		if stdin, err = source(ctx); err != nil {
+			return err
+		}
+		defer stdin.Close()
+	}
+
+	visCmd := command(ctx)
+	if visCmd.Cancel == nil {
+		visCmd.Cancel = func() error {
+			return terminate(visCmd.Process)
+		}
+	}
+	if visCmd.WaitDelay == 0 {
+		visCmd.WaitDelay = cfg.ShutdownGrace
+	}
+
+	stderr := &stderrTail{logger: cfg.Logger, limit: stderrTailLines}
+	if visCmd.Stderr == nil {
+		visCmd.Stderr = stderr
+	}
+	if stdin != nil {
+		visCmd.Stdin = stdin
+	}
+
+	stdout, err := visCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	if err := visCmd.Start(); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%w: %v", ErrFFmpegNotFound, err)
+		}
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	proc := &processReader{r: stdout, cmd: visCmd, stderr: stderr}
+	reader := bufio.NewReaderSize(proc, cfg.readBufferSize(cfg.SampleFormat))
+	err = v.processStream(ctx, reader, cfg.pcm(), false)
+
+	cancel()
+	proc.wait()
+	return err
+}
+
+// panFilter builds an ffmpeg pan filter that downmixes to mono with one
+// weight per input channel, e.g. "pan=mono|c0=0.7*c0+0.3*c1".
+func panFilter(weights []float64) string {
+	var sb strings.Builder
+	sb.WriteString("pan=mono|c0=")
+	for i, w := range weights {
+		switch {
+		case w < 0:
+			sb.WriteByte('-')
+		case i > 0:
+			sb.WriteByte('+')
+		}
+		fmt.Fprintf(&sb, "%s*c%d", strconv.FormatFloat(math.Abs(w), 'g', -1, 64), i)
+	}
+	return sb.String()
+}
+
+// LastError returns the most recent error from the stream, such as an ffmpeg
+// exit with its final stderr lines, even if a reconnect has since succeeded.
+func (v *Visualizer) LastError() error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.lastErr
+}
+
+func (v *Visualizer) setLastError(err error) {
+	if err == nil {
+		return
+	}
+	v.mu.Lock()
+	v.lastErr = err
+	v.mu.Unlock()
+
+	v.subMu.Lock()
+	v.publishEvent(ErrorEvent{At: time.Now(), Err: err})
+	v.subMu.Unlock()
+}
+
+func (v *Visualizer) CheckDependencies() error {
+	cfg := v.Config()
+	if _, err := exec.LookPath(cfg.FFmpegPath); err != nil {
+		return fmt.Errorf("%w: %v", ErrFFmpegNotFound, err)
+	}
+	if _, err := exec.LookPath(cfg.FFprobePath); err != nil {
+		return fmt.Errorf("ffprobe executable not found: %w", err)
+	}
+	return nil
+}
+
+func (v *Visualizer) StartFromReader(ctx context.Context, reader io.Reader) error {
+	cfg := v.Config()
+	return v.startFromReader(ctx, reader, cfg.pcm(), cfg.FiniteSource)
+}
+
+// StartFromStdin reads raw PCM piped into the program, for example
+//
+//	ffmpeg -i input.mp3 -ac 1 -ar 44100 -f s16le - | myapp
+//
+// The samples must match the Config: SampleRate, Channels (interleaved when
+// 2), SampleFormat and Endianness, which default to s16le.
+func (v *Visualizer) StartFromStdin(ctx context.Context) error {
+	return v.StartFromReader(ctx, os.Stdin)
+}
+
+// startFromReader streams samples encoded as pcm from reader; a finite
+// source returns nil at its first EOF instead of waiting for more data.
+func (v *Visualizer) startFromReader(ctx context.Context, reader io.Reader, pcm pcmFormat, finite bool) error {
+	ctx, done, err := v.start(ctx, "reader", "")
+	if err != nil {
+		return err
 	}
-	if cfg.Height == 0 {
-		cfg.Height = 12
+	defer done()
+
+	bufReader := bufio.NewReaderSize(reader, v.Config().readBufferSize(pcm.format))
+	err = v.processStream(ctx, bufReader, pcm, finite)
+	if ctx.Err() == nil {
+		v.setLastError(err)
 	}
-	if cfg.SampleRate == 0 {
-		cfg.SampleRate = 44100
+	return err
+}
+
+// start claims the Visualizer for a new stream. A stream that was stopped
+// but has not returned yet is waited for, so Stop followed by Start restarts
+// cleanly; one that is still live makes start fail with ErrAlreadyRunning.
+// The returned func must be called once the stream has finished.
+func (v *Visualizer) start(ctx context.Context, kind, source string) (context.Context, func(), error) {
+	v.runMu.Lock()
+	for v.runDone != nil {
+		if !v.stopping {
+			v.runMu.Unlock()
+			return nil, nil, ErrAlreadyRunning
+		}
+		prev := v.runDone
+		v.runMu.Unlock()
+		<-prev
+		v.runMu.Lock()
 	}
-	if cfg.ChunkSize == 0 {
-		cfg.ChunkSize = 1024
+	ctx, v.cancel = context.WithCancel(ctx)
+	v.runDone = make(chan struct{})
+	v.running.Store(true)
+	v.runMu.Unlock()
+
+	logger := v.Config().Logger
+	logger.Info("stream started", "source", kind, "input", source)
+	v.Reset()
+	v.mu.Lock()
+	v.startedAt = time.Now()
+	v.lastErr = nil
+	// Only URL streams carry metadata for ffprobe to poll.
+	v.streamURL = ""
+	if kind == "url" {
+		v.streamURL = source
 	}
-	if cfg.FPS == 0 {
-		cfg.FPS = 30
+	watch := v.config.AutoSize || !v.config.RawFrame
+	v.mu.Unlock()
+	if watch {
+		go v.watchResize(ctx)
 	}
-	if cfg.Char == "" {
-		cfg.Char = "|"
+
+	return ctx, func() {
+		v.closeFrames()
+		logger.Info("stream stopped", "source", kind)
+		v.mu.Lock()
+		v.streamURL = ""
+		v.mu.Unlock()
+
+		v.runMu.Lock()
+		v.cancel()
+		v.cancel = nil
+		v.stopping = false
+		v.running.Store(false)
+		close(v.runDone)
+		v.runDone = nil
+		v.runMu.Unlock()
+	}, nil
+}
+
+// Stop cancels the running stream without waiting for it; the Start call
+// returns shortly after. It is safe to call from callbacks, concurrently, or
+// when nothing is running.
+func (v *Visualizer) Stop() {
+	v.runMu.Lock()
+	defer v.runMu.Unlock()
+	if v.cancel != nil {
+		v.cancel()
+		v.stopping = true
 	}
-	if cfg.BarSpacing == 0 {
-		cfg.BarSpacing = 1
+}
+
+// IsRunning reports whether a Start call is in progress. After Stop it stays
+// true until that call has wound down.
+func (v *Visualizer) IsRunning() bool {
+	return v.running.Load()
+}
+
+// Pause freezes the display on the last frame while the source keeps being
+// read, so ffmpeg never blocks on a full pipe.
+func (v *Visualizer) Pause() {
+	v.paused.Store(true)
+}
+
+func (v *Visualizer) Resume() {
+	v.paused.Store(false)
+}
+
+func (v *Visualizer) IsPaused() bool {
+	return v.paused.Load()
+}
+
+// Reset clears the bars, peaks, beat and silence history and the current
+// track, so nothing from previous audio shows up in the next frames. The
+// balance and correlation go back to the centered, fully correlated values
+// mono reports. Every Start method calls it before reading.
+func (v *Visualizer) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for c := range v.smoothed {
+		clear(v.smoothed[c])
+		clear(v.waveform[c])
 	}
-	if cfg.Amplify == 0 {
-		cfg.Amplify = 2.5
+	clear(v.clipped)
+	v.gainPeak, v.level, v.peak = 0, 0, 0
+	v.balance, v.correlation = 0, 1
+	v.lastClip = 0
+	v.lastUpdate = time.Time{}
+	v.beats = newBeatDetector(len(v.beats.history))
+	v.silence = silenceDetector{}
+	v.track = TrackInfo{}
+	v.fetchedAt = time.Time{}
+}
+
+func (v *Visualizer) GetWaveform() []float64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return mixChannels(v.smoothed)
+}
+
+func (v *Visualizer) GetChannelWaveforms() [][]float64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	result := makeChannels(len(v.smoothed), len(v.smoothed[0]))
+	for c, ch := range v.smoothed {
+		copy(result[c], ch)
 	}
+	return result
+}
+
+func (v *Visualizer) Render() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.renderFrame(v.smoothed)
+}
+
+// RenderTo writes one frame to w. For reproducible output, drive it with
+// Update and set PerFrameSmoothing (so smoothing ignores wall-clock time) and
+// RawFrame (so no cursor escapes are written).
+func (v *Visualizer) RenderTo(w io.Writer) error {
+	_, err := io.WriteString(w, v.Render())
+	return err
+}
+
+// ansiEscape matches the cursor and color sequences renderFrame writes.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;?]*[A-Za-z]")
+
+// RenderPlain is Render without any escape sequences: just the glyph grid,
+// one line per row, plus the axis and status lines when they are shown. It
+// draws the same smoothed state as Render, for logs, grep or terminals that
+// cannot interpret escapes.
+func (v *Visualizer) RenderPlain() string {
+	return ansiEscape.ReplaceAllString(v.Render(), "")
+}
+
+func (v *Visualizer) Level() float64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.level
+}
+
+func (v *Visualizer) Peak() float64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.peak
+}
+
+func (v *Visualizer) GetTrack() TrackInfo {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.track
+}
+
+// currentURL is the URL of the running StartFromURL stream, or "" when no
+// URL is playing.
+func (v *Visualizer) currentURL() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.streamURL
+}
+
+func (v *Visualizer) FetchTrack() TrackInfo {
+	return v.FetchTrackContext(context.Background())
+}
+
+// FetchTrackContext probes the stream with ffprobe, which is killed when ctx
+// is done or after probeTimeout. Results younger than
+// TrackRefreshInterval are returned without probing again.
+func (v *Visualizer) FetchTrackContext(ctx context.Context) TrackInfo {
+	streamURL := v.currentURL()
+	if streamURL == "" || v.icy.Load() {
+		return v.GetTrack()
+	}
+
+	v.mu.RLock()
+	track, fetchedAt := v.track, v.fetchedAt
+	v.mu.RUnlock()
+	if !fetchedAt.IsZero() && time.Since(fetchedAt) < v.Config().TrackRefreshInterval {
+		return track
+	}
+
+	output, err := v.ffprobe(ctx, "-show_format", streamURL)
+	if err != nil {
+		return v.GetTrack()
+	}
+
+	var result struct {
+		Format struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+
+	if err := json.Unmarshal(output, &result); err != nil {
+		return v.GetTrack()
+	}
+
+	track = v.GetTrack()
+	if title, ok := result.Format.Tags["StreamTitle"]; ok {
+		track = v.parseTrack(title)
+	} else if title, ok := result.Format.Tags["icy-name"]; ok {
+		track.Raw = title
+		track.Title = title
+	}
+
+	v.mu.Lock()
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+
+	v.updateTrack(track)
+	return track
+}
+
+// AudioInfo describes the first audio stream of a source as ffprobe sees it.
+// ChannelLayout is ffmpeg's name for the layout, such as "stereo" or
+// "5.1(side)"; input channels are numbered in that layout's order for
+// MixWeights.
+type AudioInfo struct {
+	SampleRate    int
+	Channels      int
+	ChannelLayout string
+}
+
+// ProbeAudio asks ffprobe for the format of the first audio stream in input,
+// a URL or file path.
+func (v *Visualizer) ProbeAudio(ctx context.Context, input string) (AudioInfo, error) {
+	output, err := v.ffprobe(ctx, "-select_streams", "a:0",
+		"-show_entries", "stream=sample_rate,channels,channel_layout", input)
+	if err != nil {
+		return AudioInfo{}, fmt.Errorf("probe audio: %w", err)
+	}
+
+	var result struct {
+		Streams []struct {
+			SampleRate    string `json:"sample_rate"`
+			Channels      int    `json:"channels"`
+			ChannelLayout string `json:"channel_layout"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return AudioInfo{}, fmt.Errorf("probe audio: %w", err)
+	}
+	if len(result.Streams) == 0 {
+		return AudioInfo{}, errors.New("probe audio: no audio stream")
+	}
+	stream := result.Streams[0]
+	rate, err := strconv.Atoi(stream.SampleRate)
+	if err != nil {
+		return AudioInfo{}, fmt.Errorf("probe audio: %w", err)
+	}
+	return AudioInfo{SampleRate: rate, Channels: stream.Channels, ChannelLayout: stream.ChannelLayout}, nil
+}
+
+// ProbeSampleRate returns the sample rate ProbeAudio reports. The Start
+// methods trust Config.SampleRate, so raw PCM at a different rate silently
+// plays too fast or too slow; probe the source first to catch that.
+func (v *Visualizer) ProbeSampleRate(ctx context.Context, input string) (int, error) {
+	info, err := v.ProbeAudio(ctx, input)
+	return info.SampleRate, err
+}
+
+// checkSource logs the stream's layout and warns about settings that do not
+// fit it. A native rate other than SampleRate is resampled by ffmpeg, so the
+// bars are right, but audio played from the same URL by another program runs
+// at the native rate.
+func (v *Visualizer) checkSource(ctx context.Context, input string) {
+	cfg := v.Config()
+	info, err := v.ProbeAudio(ctx, input)
+	if err != nil {
+		cfg.Logger.Debug("audio probe failed", "err", err)
+		return
+	}
+	cfg.Logger.Info("source audio", "rate", info.SampleRate, "channels", info.Channels, "layout", info.ChannelLayout)
+
+	if math.Abs(float64(info.SampleRate-cfg.SampleRate)) > sampleRateTolerance*float64(cfg.SampleRate) {
+		cfg.Logger.Warn("stream sample rate differs from config; ffmpeg resamples it",
+			"stream", info.SampleRate, "config", cfg.SampleRate)
+	}
+	if n := len(cfg.MixWeights); n > 0 && n != info.Channels {
+		cfg.Logger.Warn("mix weights do not match the stream's channels",
+			"weights", n, "channels", info.Channels, "layout", info.ChannelLayout)
+	}
+}
+
+// ffprobe runs ffprobe with JSON output for args, giving up after
+// probeTimeout.
+func (v *Visualizer) ffprobe(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	args = append([]string{"-v", "quiet", "-print_format", "json"}, args...)
+	return exec.CommandContext(ctx, v.Config().FFprobePath, args...).Output()
+}
+
+// TrackFetchedAt reports when ffprobe last returned metadata, or the zero
+// time if it never has.
+func (v *Visualizer) TrackFetchedAt() time.Time {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.fetchedAt
+}
+
+func (v *Visualizer) setStreamTitle(title string) {
+	v.updateTrack(v.parseTrack(title))
+}
+
+func (v *Visualizer) updateTrack(track TrackInfo) {
+	v.mu.Lock()
+	changed := track != v.track
+	v.track = track
+	v.mu.Unlock()
+
+	if changed {
+		v.publishTrack(track)
+	}
+}
+
+// TrackChanges delivers the track whenever its metadata changes. Without
+// ICYMetadata the stream is polled with FetchTrack. The channel is closed
+// when ctx is done.
+func (v *Visualizer) TrackChanges(ctx context.Context) <-chan TrackInfo {
+	ch := make(chan TrackInfo, 1)
+
+	v.subMu.Lock()
+	if v.trackSubs == nil {
+		v.trackSubs = make(map[chan TrackInfo]struct{})
+	}
+	v.trackSubs[ch] = struct{}{}
+	v.subMu.Unlock()
+
+	go func() {
+		v.pollTrack(ctx)
+		v.subMu.Lock()
+		delete(v.trackSubs, ch)
+		close(ch)
+		v.subMu.Unlock()
+	}()
+
+	return ch
+}
+
+// pollTrack fetches the track every trackPollInterval until ctx is done,
+// unless ICY metadata is already keeping it current.
+func (v *Visualizer) pollTrack(ctx context.Context) {
+	ticker := time.NewTicker(trackPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !v.icy.Load() {
+				v.FetchTrackContext(ctx)
+			}
+		}
+	}
+}
+
+func (v *Visualizer) publishTrack(track TrackInfo) {
+	v.subMu.Lock()
+	defer v.subMu.Unlock()
+	v.publishEvent(TrackEvent{At: time.Now(), Track: track})
+	for ch := range v.trackSubs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- track
+	}
+}
+
+// parseTrack applies TrackRegex when it matches and falls back to splitting
+// on the separators.
+func (v *Visualizer) parseTrack(title string) TrackInfo {
+	if re := v.config.TrackRegex; re != nil {
+		if m := re.FindStringSubmatch(title); m != nil {
+			track := TrackInfo{Raw: title}
+			if i := re.SubexpIndex("artist"); i >= 0 {
+				track.Artist = strings.TrimSpace(m[i])
+			}
+			if i := re.SubexpIndex("title"); i >= 0 {
+				track.Title = strings.TrimSpace(m[i])
+			}
+			return track
+		}
+	}
+	return parseTrack(title, v.config.TrackSeparators)
+}
+
+// parseTrack splits "Artist - Title" on the first separator that is not
+// inside parentheses or brackets, so "AC/DC - Hells Bells (Remastered - 2003)"
+// keeps its suffix in the title.
+func parseTrack(title string, separators []string) TrackInfo {
+	track := TrackInfo{Raw: title, Title: strings.TrimSpace(title)}
+
+	depth := 0
+	for i := 0; i < len(title); i++ {
+		switch title[i] {
+		case '(', '[', '{':
+			depth++
+			continue
+		case ')', ']', '}':
+			depth = max(depth-1, 0)
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
 
-	return &Visualizer{
-		config:   cfg,
-		waveform: make([]float64, cfg.Width),
-		smoothed: make([]float64, cfg.Width),
+		sep := ""
+		for _, candidate := range separators {
+			if len(candidate) > len(sep) && strings.HasPrefix(title[i:], candidate) {
+				sep = candidate
+			}
+		}
+		if sep != "" {
+			track.Artist = strings.TrimSpace(title[:i])
+			track.Title = strings.TrimSpace(title[i+len(sep):])
+			track.Separator = sep
+			break
+		}
 	}
+	return track
 }
 
-func (v *Visualizer) StartFromURL(ctx context.Context, streamURL string) error {
-	ctx, v.cancel = context.WithCancel(ctx)
-	v.running = true
-	v.streamURL = streamURL
-
-	visCmd := exec.CommandContext(ctx, "ffmpeg",
-		"-probesize", "32k",
-		"-analyzeduration", "0",
-		"-fflags", "nobuffer",
-		"-flags", "low_delay",
-		"-i", streamURL,
-		"-ac", "1",
-		"-ar", strconv.Itoa(v.config.SampleRate),
-		"-f", "s16le",
-		"-acodec", "pcm_s16le",
-		"-vn",
-		"-",
-	)
-
-	stdout, err := visCmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create pipe: %w", err)
-	}
+func (v *Visualizer) Frames(ctx context.Context) <-chan string {
+	ch := make(chan string, 1)
 
-	if err := visCmd.Start(); err != nil {
-		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	v.subMu.Lock()
+	if v.frameSubs == nil {
+		v.frameSubs = make(map[chan string]struct{})
 	}
+	v.frameSubs[ch] = struct{}{}
+	v.subMu.Unlock()
 
 	go func() {
 		<-ctx.Done()
-		visCmd.Process.Kill()
+		v.subMu.Lock()
+		defer v.subMu.Unlock()
+		if _, ok := v.frameSubs[ch]; ok {
+			delete(v.frameSubs, ch)
+			close(ch)
+		}
 	}()
 
-	reader := bufio.NewReaderSize(stdout, v.config.ChunkSize*4)
-	return v.processStream(ctx, reader)
+	return ch
 }
 
-func (v *Visualizer) StartFromReader(ctx context.Context, reader io.Reader) error {
-	ctx, v.cancel = context.WithCancel(ctx)
-	v.running = true
+// Subscribe returns a channel that receives the smoothed bars (channels
+// averaged, as from GetWaveform) after every update, and a function that
+// unsubscribes and closes it. A slow receiver only misses intermediate
+// values: each send replaces the one still pending.
+func (v *Visualizer) Subscribe() (<-chan []float64, func()) {
+	ch := make(chan []float64, 1)
+
+	v.subMu.Lock()
+	if v.waveSubs == nil {
+		v.waveSubs = make(map[chan []float64]struct{})
+	}
+	v.waveSubs[ch] = struct{}{}
+	v.subMu.Unlock()
 
-	bufReader := bufio.NewReaderSize(reader, v.config.ChunkSize*4)
-	return v.processStream(ctx, bufReader)
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			v.subMu.Lock()
+			defer v.subMu.Unlock()
+			delete(v.waveSubs, ch)
+			close(ch)
+		})
+	}
 }
 
-func (v *Visualizer) Stop() {
-	if v.cancel != nil {
-		v.cancel()
+func (v *Visualizer) publishWaveform() {
+	v.subMu.Lock()
+	defer v.subMu.Unlock()
+	if len(v.waveSubs) == 0 {
+		return
+	}
+
+	bars := v.GetWaveform()
+	for ch := range v.waveSubs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- bars
 	}
-	v.running = false
 }
 
-func (v *Visualizer) IsRunning() bool {
-	return v.running
+func (v *Visualizer) publishFrame(frame string) {
+	v.subMu.Lock()
+	defer v.subMu.Unlock()
+	v.publishEvent(FrameEvent{At: time.Now(), Frame: frame})
+	for ch := range v.frameSubs {
+		select {
+		case ch <- frame:
+		default:
+		}
+	}
 }
 
-func (v *Visualizer) GetWaveform() []float64 {
-	v.mu.RLock()
-	defer v.mu.RUnlock()
-	result := make([]float64, len(v.smoothed))
-	copy(result, v.smoothed)
-	return result
+// hasFrameSubs reports whether any Frames or Events subscriber is waiting
+// for text frames.
+func (v *Visualizer) hasFrameSubs() bool {
+	v.subMu.Lock()
+	defer v.subMu.Unlock()
+	return len(v.frameSubs) > 0 || len(v.eventSubs) > 0
 }
 
-func (v *Visualizer) Render() string {
-	v.mu.RLock()
-	defer v.mu.RUnlock()
-	return v.renderFrame(v.smoothed)
+func (v *Visualizer) closeFrames() {
+	v.subMu.Lock()
+	defer v.subMu.Unlock()
+	for ch := range v.frameSubs {
+		delete(v.frameSubs, ch)
+		close(ch)
+	}
+	v.closeEvents()
 }
 
-func (v *Visualizer) GetTrack() TrackInfo {
+func (v *Visualizer) chunkCount() uint64 {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
-	return v.track
+	return v.chunks
 }
 
-func (v *Visualizer) FetchTrack() TrackInfo {
-	if v.streamURL == "" {
-		return v.track
+func (v *Visualizer) processStream(ctx context.Context, reader *bufio.Reader, pcm pcmFormat, finite bool) error {
+	// Snapshot the config: the stream-fixed fields cannot change until the
+	// stream ends, and copying v.config itself would race with SetConfig
+	// writing the live ones.
+	cfg := v.Config()
+	if !cfg.RawFrame {
+		autoVirtualTerminal()
 	}
+	channels := cfg.Channels
+	format := pcm.format
+	sampleSize := format.bytesPerSample()
+	order := pcm.endian.byteOrder()
+	rawBuffer := make([]byte, cfg.ChunkSize*sampleSize*channels)
+	buffers := makeChannels(channels, cfg.frameSamples())
+	tail := cfg.frameSamples() - cfg.ChunkSize
+	var tapBuffer []float64
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "ffprobe",
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		v.streamURL,
-	)
+	updateInterval := cfg.frameInterval()
+	tickInterval := cfg.tickInterval()
+	eofCount := 0
 
-	output, err := cmd.Output()
-	if err != nil {
-		return v.track
+	// write runs one frame write, a Renderer's or Output's, reporting
+	// whether it went out.
+	write := func(frame func() error) (bool, error) {
+		err := frame()
+		return err == nil, err
 	}
-
-	var result struct {
-		Format struct {
-			Tags map[string]string `json:"tags"`
-		} `json:"format"`
+	if cfg.DropFrames {
+		out := newAsyncWriter()
+		defer out.close()
+		write = func(frame func() error) (bool, error) {
+			queued, err := out.write(frame)
+			if !queued && err == nil {
+				v.stats.drop()
+			}
+			return queued, err
+		}
 	}
+	filled := 0
 
-	if err := json.Unmarshal(output, &result); err != nil {
-		return v.track
+	var writeMu sync.Mutex
+	var lastFrame string
+	var lastValues [][]float64
+	renderer := cfg.Renderer
+	render := v.Render
+	if renderer != nil {
+		// The Renderer draws from the channel values, so the text frame is
+		// only needed by Frames and Events subscribers.
+		render = func() string {
+			if !v.hasFrameSubs() {
+				return ""
+			}
+			return v.Render()
+		}
 	}
-
-	v.mu.Lock()
-	defer v.mu.Unlock()
-
-	if title, ok := result.Format.Tags["StreamTitle"]; ok {
-		v.track.Raw = title
-		if parts := strings.SplitN(title, " - ", 2); len(parts) == 2 {
-			v.track.Artist = strings.TrimSpace(parts[0])
-			v.track.Title = strings.TrimSpace(parts[1])
-		} else {
-			v.track.Title = title
-			v.track.Artist = ""
+	emit := func(frame string) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		// A resize or config change forces the next frame out even when it
+		// matches, since the terminal may no longer show the last one.
+		forced := v.redraw.Swap(false)
+		if renderer != nil {
+			values := v.GetChannelWaveforms()
+			if !cfg.SkipUnchanged || forced || !equalChannels(values, lastValues) {
+				written, err := write(func() error { return renderer.WriteFrame(values) })
+				if err != nil {
+					return err
+				}
+				if written {
+					lastValues = values
+				} else {
+					v.redraw.Store(true)
+				}
+			}
+			if frame != "" {
+				v.publishFrame(frame)
+			}
+			return nil
 		}
-	} else if title, ok := result.Format.Tags["icy-name"]; ok {
-		v.track.Raw = title
-		v.track.Title = title
+		if !cfg.SkipUnchanged || forced || frame != lastFrame {
+			out := frame
+			clear := v.clear.Swap(false)
+			if clear {
+				out = v.clearRegion() + frame
+			}
+			written, err := write(func() error {
+				_, err := io.WriteString(cfg.Output, out)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if written {
+				lastFrame = frame
+			} else {
+				// Keep the clear and the redraw for the next frame that
+				// makes it out.
+				if clear {
+					v.clear.Store(true)
+				}
+				v.redraw.Store(true)
+			}
+		}
+		v.publishFrame(frame)
+		return nil
 	}
 
-	return v.track
-}
-
-func (v *Visualizer) processStream(ctx context.Context, reader *bufio.Reader) error {
-	rawBuffer := make([]byte, v.config.ChunkSize*2)
-	buffer := make([]int16, v.config.ChunkSize)
-	waveform := make([]float64, v.config.Width)
-
-	updateInterval := time.Second / time.Duration(v.config.FPS)
+	// With RenderTicker the frames come from renderOnTicks and reads only
+	// update the analysis; otherwise every chunk renders one frame and
+	// watchStall covers the gaps.
+	var lastChunk atomic.Int64
+	lastChunk.Store(time.Now().UnixNano())
+	var renderErr chan error
+	ticked := cfg.RenderTicker
+	tickCtx, stopTicks := context.WithCancel(ctx)
+	ticksDone := make(chan struct{})
+	if ticked {
+		renderErr = make(chan error, 1)
+	}
+	go func() {
+		defer close(ticksDone)
+		if ticked {
+			v.renderOnTicks(tickCtx, &lastChunk, tickInterval, render, emit, renderErr)
+		} else {
+			v.watchStall(tickCtx, &lastChunk, tickInterval, render, emit)
+		}
+	}()
+	defer func() {
+		stopTicks()
+		<-ticksDone
+	}()
 
 	for {
 		select {
 		case <-ctx.Done():
-			v.running = false
 			return ctx.Err()
+		case err := <-renderErr:
+			return err
 		default:
 		}
 
 		startTime := time.Now()
 
-		n, err := io.ReadFull(reader, rawBuffer)
+		// A short read keeps its bytes so the next read completes the chunk
+		// instead of shifting every later sample off its boundary.
+		n, err := io.ReadFull(reader, rawBuffer[filled:])
+		filled += n
 		if err != nil {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				if finite {
+					cfg.Logger.Info("source finished")
+					return nil
+				}
+				if n > 0 {
+					eofCount = 0
+				}
+				eofCount++
+				if eofCount > eofRetryLimit {
+					cfg.Logger.Warn("stream ended", "retries", eofRetryLimit)
+					return ErrStreamEnded
+				}
+				cfg.Logger.Debug("stream underrun", "attempt", eofCount)
+				if err := sleepContext(ctx, eofBackoff(eofCount)); err != nil {
+					return err
+				}
 				continue
 			}
 			return err
 		}
-		if n < len(rawBuffer) {
-			continue
+		filled = 0
+		eofCount = 0
+		readDone := time.Now()
+		lastChunk.Store(readDone.UnixNano())
+
+		// With FramesPerRender > 1 the buffers hold a sliding window of the
+		// latest chunks, so each frame integrates more audio at the same FPS.
+		for _, buffer := range buffers {
+			copy(buffer, buffer[cfg.ChunkSize:])
+		}
+		for i := range cfg.ChunkSize {
+			for c, buffer := range buffers {
+				j := (i*channels + c) * sampleSize
+				buffer[tail+i] = format.decodeSample(rawBuffer[j:j+sampleSize], order)
+			}
+		}
+		v.stats.decoded(cfg.ChunkSize, len(rawBuffer))
+		v.mu.RLock()
+		tap := v.config.Tap
+		v.mu.RUnlock()
+		if tap != nil {
+			if tapBuffer == nil {
+				tapBuffer = make([]float64, cfg.ChunkSize*channels)
+			}
+			for i := range tapBuffer {
+				tapBuffer[i] = buffers[i%channels][tail+i/channels]
+			}
+			tap(tapBuffer)
 		}
 
-		for i := range v.config.ChunkSize {
-			buffer[i] = int16(rawBuffer[i*2]) | int16(rawBuffer[i*2+1])<<8
+		if ticked {
+			if !v.paused.Load() {
+				v.update(buffers)
+			}
+			continue
+		}
+		if v.paused.Load() {
+			time.Sleep(time.Until(startTime.Add(updateInterval)))
+			continue
 		}
 
-		v.convertToWaveform(buffer, waveform)
+		v.update(buffers)
 
-		v.mu.Lock()
-		for i := range waveform {
-			v.smoothed[i] = v.smoothed[i]*(1-v.config.SmoothFactor) + waveform[i]*v.config.SmoothFactor
+		frame := render()
+		processDone := time.Now()
+		if err := emit(frame); err != nil {
+			return err
 		}
-		v.mu.Unlock()
-
-		fmt.Print(v.Render())
 
 		elapsed := time.Since(startTime)
-		if elapsed < updateInterval {
+		overrun := updateInterval > 0 && elapsed > updateInterval
+		v.stats.record(frameTiming{
+			at:      startTime,
+			read:    readDone.Sub(startTime),
+			process: processDone.Sub(readDone),
+			write:   time.Since(processDone),
+		}, overrun)
+		if overrun {
+			cfg.Logger.Debug("frame overrun", "elapsed", elapsed, "interval", updateInterval)
+		}
+		if elapsed < updateInterval && !cfg.LowLatency {
 			time.Sleep(updateInterval - elapsed)
 		}
 	}
 }
 
-func (v *Visualizer) convertToWaveform(buffer []int16, waveform []float64) {
-	samplesPerColumn := len(buffer) / len(waveform)
+// renderOnTicks renders a frame every interval for RenderTicker, whether or
+// not a chunk arrived since the last one, so bursty sources still give a
+// steady frame rate. Stalled bars decay as in watchStall. The first failed
+// write goes to errc, where processStream picks it up after its next read.
+func (v *Visualizer) renderOnTicks(ctx context.Context, lastChunk *atomic.Int64, interval time.Duration, render func() string, emit func(string) error, errc chan<- error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	for col := range waveform {
-		start := col * samplesPerColumn
-		end := min(start+samplesPerColumn, len(buffer))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if v.paused.Load() {
+				continue
+			}
+			if now.Sub(time.Unix(0, lastChunk.Load())) >= stallTimeout {
+				v.decayStalled()
+			}
 
-		sum := 0.0
-		for i := start; i < end; i++ {
-			value := float64(buffer[i]) / 32768.0
-			sum += value * value
+			startTime := time.Now()
+			frame := render()
+			renderDone := time.Now()
+			if err := emit(frame); err != nil {
+				errc <- err
+				return
+			}
+			elapsed := time.Since(startTime)
+			v.stats.record(frameTiming{
+				at:      startTime,
+				process: renderDone.Sub(startTime),
+				write:   time.Since(renderDone),
+			}, elapsed > interval)
 		}
+	}
+}
 
-		if samplesPerColumn > 0 {
-			waveform[col] = math.Sqrt(sum / float64(samplesPerColumn))
+// Update runs one analysis pass over samples, interleaved when Channels is
+// 2, without reading, rendering or sleeping. Together with Render and
+// GetWaveform it lets callers drive the visualizer from their own audio
+// source and loop.
+func (v *Visualizer) Update(samples []float64) {
+	channels := v.config.Channels
+	buffers := makeChannels(channels, len(samples)/channels)
+	if len(buffers[0]) == 0 {
+		return
+	}
+	for i, buffer := range buffers {
+		for j := range buffer {
+			buffer[j] = samples[j*channels+i]
 		}
 	}
+	v.update(buffers)
 }
 
-func (v *Visualizer) renderFrame(waveform []float64) string {
-	var sb strings.Builder
-	sb.Grow(v.config.Width * v.config.Height * 4)
+// update analyses one set of per-channel buffers and advances the smoothed
+// state, then fires the beat and silence callbacks.
+func (v *Visualizer) update(buffers [][]float64) {
+	level, peak := measureLevel(buffers)
+	balance, correlation := measureStereo(buffers)
 
-	sb.WriteString("\033[2;0H")
-	sb.WriteString("\033[?25l")
+	v.mu.Lock()
+	for c, buffer := range buffers {
+		v.convertToWaveform(buffer, v.waveform[c])
+		if v.config.SpectralSmooth > 0 {
+			smoothAcross(v.waveform[c], v.config.SpectralSmooth)
+		}
+	}
+	v.chunks++
+	if v.recorder != nil {
+		v.recorder.push(mixChannels(v.waveform))
+	}
+	v.level, v.peak = level, peak
+	v.balance, v.correlation = balance, correlation
+	v.markClipping(buffers, peak >= clipLevel)
+	beat := v.config.OnBeat != nil && v.beats.detect(level*level, v.audioTime(), v.config.BeatSensitivity, v.config.BeatMinInterval)
+	silenced, resumed := v.silence.update(level, v.audioTime(), v.config.SilenceThreshold, v.config.SilenceDuration)
+	dt := v.frameDelta(v.config.tickInterval())
+	v.smooth(dt)
+	if v.config.AutoGain {
+		v.updateGain(math.Pow(0.5, dt.Seconds()/autoGainHalfLife.Seconds()))
+	}
+	onBeat, onSilence, onResume := v.config.OnBeat, v.config.OnSilence, v.config.OnResume
+	v.mu.Unlock()
 
-	midline := v.config.Height / 2
+	v.publishWaveform()
 
-	for row := range v.config.Height {
-		for col := range v.config.Width {
-			if v.config.BarSpacing > 1 && col%v.config.BarSpacing != 0 {
-				sb.WriteByte(' ')
-				continue
-			}
+	if beat {
+		onBeat()
+	}
+	if silenced && onSilence != nil {
+		onSilence()
+	}
+	if resumed && onResume != nil {
+		onResume()
+	}
+}
 
-			waveIdx := col
-			if v.config.BarSpacing > 1 {
-				waveIdx = col / v.config.BarSpacing
-			}
-			if waveIdx >= len(waveform) {
-				sb.WriteByte(' ')
-				continue
+// smooth blends the latest waveform into the smoothed state, using the
+// attack factor for rising values and the release factor for falling ones.
+func (v *Visualizer) smooth(dt time.Duration) {
+	// Blending successive traces of a signal would only blur its shape.
+	if v.config.Mode == ModeScope {
+		for c := range v.waveform {
+			copy(v.smoothed[c], v.waveform[c])
+		}
+		return
+	}
+
+	attack := v.smoothingAlpha(cmp.Or(v.config.AttackFactor, v.config.SmoothFactor), dt)
+	release := v.smoothingAlpha(cmp.Or(v.config.ReleaseFactor, v.config.SmoothFactor), dt)
+
+	for c, waveform := range v.waveform {
+		for i, value := range waveform {
+			alpha := release
+			if value > v.smoothed[c][i] {
+				alpha = attack
 			}
+			v.smoothed[c][i] = v.smoothed[c][i]*(1-alpha) + value*alpha
+		}
+	}
+}
 
-			height := int(waveform[waveIdx] * v.config.Amplify * float64(midline-1))
-			height = min(height, midline-1)
+// frameDelta returns the time since the previous smoothing step, using the
+// nominal frame interval for the first one.
+func (v *Visualizer) frameDelta(nominal time.Duration) time.Duration {
+	now := time.Now()
+	dt := nominal
+	if !v.lastUpdate.IsZero() {
+		dt = min(now.Sub(v.lastUpdate), maxSmoothingStep)
+	}
+	v.lastUpdate = now
+	return dt
+}
 
-			if row >= midline-height && row <= midline+height && height > 0 {
-				sb.WriteString(v.config.Char)
-			} else {
-				sb.WriteByte(' ')
+// smoothingAlpha converts a per-frame blend factor, defined at the
+// smoothingReference frame rate, into the blend for a step of dt so the
+// decay looks the same at any FPS.
+func (v *Visualizer) smoothingAlpha(factor float64, dt time.Duration) float64 {
+	if v.config.PerFrameSmoothing || factor >= 1 || factor <= 0 {
+		return factor
+	}
+	return 1 - math.Pow(1-factor, dt.Seconds()/smoothingReference.Seconds())
+}
+
+func (v *Visualizer) audioTime() time.Duration {
+	return time.Duration(v.chunks) * time.Duration(v.config.ChunkSize) * time.Second / time.Duration(v.config.SampleRate)
+}
+
+// measureLevel returns the RMS and absolute peak of a chunk across all
+// channels.
+func measureLevel(buffers [][]float64) (rms, peak float64) {
+	sum, count := 0.0, 0
+	for _, buffer := range buffers {
+		for _, sample := range buffer {
+			sum += sample * sample
+			peak = max(peak, math.Abs(sample))
+		}
+		count += len(buffer)
+	}
+	if count > 0 {
+		rms = math.Sqrt(sum / float64(count))
+	}
+	return rms, peak
+}
+
+// markClipping flags the bands that saw a sample at full scale: the columns
+// covering it in ModeWaveform, or every band in ModeSpectrum.
+func (v *Visualizer) markClipping(buffers [][]float64, clipped bool) {
+	bands := len(v.waveform[0])
+	if len(v.clipped) != bands {
+		v.clipped = make([]bool, bands)
+	}
+	for i := range v.clipped {
+		v.clipped[i] = clipped && v.config.Mode == ModeSpectrum
+	}
+	if !clipped {
+		return
+	}
+
+	v.lastClip = v.audioTime()
+	if v.config.Mode == ModeSpectrum {
+		return
+	}
+	for _, buffer := range buffers {
+		for col := range v.clipped {
+			start, end := columnRange(col, bands, len(buffer))
+			for _, sample := range buffer[start:end] {
+				if math.Abs(sample) >= clipLevel {
+					v.clipped[col] = true
+					break
+				}
 			}
 		}
-		sb.WriteByte('\n')
 	}
+}
+
+// Clipping reports whether the source hit full scale within the last second
+// of audio.
+func (v *Visualizer) Clipping() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.lastClip > 0 && v.audioTime()-v.lastClip < clipHold
+}
+
+func (v *Visualizer) updateGain(decay float64) {
+	peak := v.gainPeak * decay
+	for _, ch := range v.smoothed {
+		for _, value := range ch {
+			peak = max(peak, math.Abs(value))
+		}
+	}
+	v.gainPeak = peak
+}
+
+func eofBackoff(attempt int) time.Duration {
+	return min(eofBackoffMin*time.Duration(attempt), eofBackoffMax)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
 
-	if v.config.ShowStatus {
-		sb.WriteString(fmt.Sprintf("Audio Visualizer | %dHz | %d samples | %d FPS\n",
-			v.config.SampleRate, v.config.ChunkSize, v.config.FPS))
+func (v *Visualizer) convertToWaveform(buffer []float64, waveform []float64) {
+	switch v.config.Mode {
+	case ModeSpectrum:
+		v.convertToSpectrum(buffer, waveform)
+		return
+	case ModeScope:
+		for col := range waveform {
+			start, end := columnRange(col, len(waveform), len(buffer))
+			waveform[col] = buffer[(start+end)/2]
+		}
+		return
 	}
 
-	return sb.String()
+	for col := range waveform {
+		start, end := columnRange(col, len(waveform), len(buffer))
+		waveform[col] = aggregate(buffer[start:end], v.config.Aggregation)
+	}
+}
+
+// aggregate reduces samples to one magnitude: their RMS, largest absolute
+// value, or mean absolute value.
+func aggregate(samples []float64, kind Aggregation) float64 {
+	result := 0.0
+	for _, s := range samples {
+		switch kind {
+		case AggregationPeak:
+			result = max(result, math.Abs(s))
+		case AggregationMean:
+			result += math.Abs(s)
+		default:
+			result += s * s
+		}
+	}
+
+	switch kind {
+	case AggregationPeak:
+		return result
+	case AggregationMean:
+		return result / float64(len(samples))
+	default:
+		return math.Sqrt(result / float64(len(samples)))
+	}
+}
+
+// transform adds the magnitude spectrum of one windowed segment to mags.
+func (v *Visualizer) transform(segment []float64) {
+	for i := range v.fftBuf {
+		if i < len(segment) && i < len(v.window) {
+			v.fftBuf[i] = complex(segment[i]*v.window[i], 0)
+		} else {
+			v.fftBuf[i] = 0
+		}
+	}
+
+	fft(v.fftBuf)
+
+	for k := range v.mags {
+		v.mags[k] += cmplx.Abs(v.fftBuf[k])
+	}
+}
+
+// smoothAcross replaces every value with the mean of its neighbors within
+// radius, shrinking the window at the edges instead of wrapping around.
+func smoothAcross(values []float64, radius int) {
+	sums := make([]float64, len(values)+1)
+	for i, value := range values {
+		sums[i+1] = sums[i] + value
+	}
+	for i := range values {
+		lo, hi := max(i-radius, 0), min(i+radius+1, len(values))
+		values[i] = (sums[hi] - sums[lo]) / float64(hi-lo)
+	}
+}
+
+// columnRange returns the samples [start, end) that a waveform column covers.
+// Columns cover proportional ranges so that no samples are dropped and, when
+// there are more columns than samples, each still gets one.
+func columnRange(col, cols, n int) (start, end int) {
+	start = min(col*n/cols, n-1)
+	end = max((col+1)*n/cols, start+1)
+	return start, end
+}
+
+// convertToSpectrum averages the magnitudes of window-sized segments taken
+// back from the newest sample, FFTOverlap apart, so a buffer longer than
+// the window yields a smoother spectrum instead of discarding audio.
+func (v *Visualizer) convertToSpectrum(buffer []float64, spectrum []float64) {
+	length := len(v.window)
+	hop := max(int(float64(length)*(1-v.config.FFTOverlap)), 1)
+
+	clear(v.mags)
+	segments := 0
+	for end := len(buffer); ; end -= hop {
+		v.transform(buffer[max(end-length, 0):end])
+		segments++
+		if end-hop < length {
+			break
+		}
+	}
+	for k := range v.mags {
+		v.mags[k] *= v.winScale / float64(segments)
+	}
+
+	for col := range spectrum {
+		spectrum[col] = bandValue(v.mags, v.binEdge(col, len(spectrum)), v.binEdge(col+1, len(spectrum)))
+		if v.gains != nil {
+			spectrum[col] *= v.gains[col]
+		}
+	}
+}
+
+func (v *Visualizer) binEdge(col, width int) float64 {
+	bins := float64(len(v.mags) - 1)
+	pos := float64(col) / float64(width)
+	if v.config.LogScale {
+		return math.Pow(bins, pos)
+	}
+	return 1 + pos*(bins-1)
+}
+
+// FrequencyForColumn returns the center frequency in Hz of the band shown in
+// a display column (a row in horizontal orientation), or 0 outside
+// ModeSpectrum.
+func (v *Visualizer) FrequencyForColumn(col int) float64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.columnFrequency(col)
+}
+
+func (v *Visualizer) columnFrequency(col int) float64 {
+	if v.config.Mode != ModeSpectrum || col < 0 {
+		return 0
+	}
+	return v.bandFrequency(v.columnBand(col), v.bandCount())
+}
+
+// bandFrequency returns the center frequency in Hz of a spectrum band.
+func (v *Visualizer) bandFrequency(band, bands int) float64 {
+	lo, hi := v.binEdge(band, bands), v.binEdge(band+1, bands)
+	center := (lo + hi) / 2
+	if v.config.LogScale {
+		center = math.Sqrt(lo * hi)
+	}
+	return center * float64(v.config.SampleRate) / float64(len(v.fftBuf))
+}
+
+// bandGains combines Weighting and BandGains into one gain per band, or nil
+// when every band is left as is.
+func (v *Visualizer) bandGains(bands int) []float64 {
+	if v.config.Weighting == WeightingNone && len(v.config.BandGains) == 0 {
+		return nil
+	}
+
+	gains := make([]float64, bands)
+	for i := range gains {
+		gains[i] = 1
+	}
+	if v.config.Weighting == WeightingA {
+		for i := range gains {
+			gains[i] = aWeighting(v.bandFrequency(i, bands))
+		}
+	}
+	if len(v.config.BandGains) > 0 {
+		custom := v.config.BandGains
+		if len(custom) != bands {
+			custom = resample(custom, bands)
+		}
+		for i := range gains {
+			gains[i] *= custom[i]
+		}
+	}
+	return gains
+}
+
+// columnBand returns the band shown at a display column (a row in horizontal
+// orientation); braille cells start with the first of their two bands.
+func (v *Visualizer) columnBand(col int) int {
+	if v.braille() && v.config.Orientation != OrientationHorizontal {
+		col *= 2
+	}
+	if v.config.Bands > 0 {
+		return col * v.config.Bands / v.positionCount()
+	}
+	return col / max(v.config.BarSpacing, 1)
+}
+
+// bandCount is the number of values the DSP produces per channel: one per
+// bar, and with BarSpacing only every BarSpacing-th position holds a bar.
+func (v *Visualizer) bandCount() int {
+	if v.config.Bands > 0 {
+		return v.config.Bands
+	}
+	return ceilDiv(v.positionCount(), max(v.config.BarSpacing, 1))
+}
+
+// positionCount is the number of bar positions along the band axis.
+func (v *Visualizer) positionCount() int {
+	switch {
+	case v.config.Orientation == OrientationHorizontal:
+		return v.config.Height
+	case v.braille():
+		return v.config.Width * 2
+	default:
+		return v.config.Width
+	}
+}
+
+// equalChannels reports whether a and b hold the same values.
+func equalChannels(a, b [][]float64) bool {
+	return slices.EqualFunc(a, b, slices.Equal[[]float64])
+}
+
+func mixChannels(channels [][]float64) []float64 {
+	result := make([]float64, len(channels[0]))
+	for _, ch := range channels {
+		for i, value := range ch {
+			result[i] += value / float64(len(channels))
+		}
+	}
+	return result
+}
+
+func makeChannels(channels, width int) [][]float64 {
+	result := make([][]float64, channels)
+	for c := range result {
+		result[c] = make([]float64, width)
+	}
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
 }
 
 func ClearScreen() {
-	fmt.Print("\033[2J")
+	autoVirtualTerminal()
+	ClearScreenTo(os.Stdout)
+}
+
+func ClearScreenTo(w io.Writer) {
+	io.WriteString(w, "\033[2J")
 }
 
 func ShowCursor() {
-	fmt.Print("\033[?25h")
+	autoVirtualTerminal()
+	ShowCursorTo(os.Stdout)
+}
+
+func ShowCursorTo(w io.Writer) {
+	io.WriteString(w, "\033[?25h")
 }
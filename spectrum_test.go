@@ -0,0 +1,357 @@
+package spectrum
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os/exec"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer that tolerates writes from the stall and
+// ticker goroutines alongside reads from the test.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// TestSetConfigWhileStreaming changes live settings while a stream renders;
+// run it with -race to check the stream only reads its config snapshot.
+func TestSetConfigWhileStreaming(t *testing.T) {
+	for _, ticker := range []bool{false, true} {
+		v := New(Config{Output: &syncBuffer{}, Width: 40, Height: 8, StallDecay: 0.5, RenderTicker: ticker})
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				cfg := v.Config()
+				cfg.Amplify++
+				cfg.RawFrame = !cfg.RawFrame
+				cfg.Mode = ModeSpectrum
+				if err := v.SetConfig(cfg); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := v.SetAmplify(2); err != nil {
+					t.Error(err)
+					return
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_ = v.StreamJSON(ctx, &syncBuffer{})
+		}()
+
+		if err := v.StartFromTone(ctx, 440); err != nil && err != context.DeadlineExceeded {
+			t.Errorf("ticker %v: %v", ticker, err)
+		}
+		cancel()
+		wg.Wait()
+	}
+}
+
+// TestSetConfigWhileStarting replaces the whole config while streams start
+// and stop; run it with -race to check the start and stop paths only read
+// config snapshots.
+func TestSetConfigWhileStarting(t *testing.T) {
+	v := New(Config{Output: &syncBuffer{}})
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil {
+			cfg := v.Config()
+			cfg.ChunkSize = 1536 - cfg.ChunkSize
+			cfg.ReconnectAttempts = 3 - cfg.ReconnectAttempts
+			if err := v.SetConfig(cfg); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for ctx.Err() == nil {
+		runCtx, stop := context.WithTimeout(ctx, 10*time.Millisecond)
+		_ = v.StartFromTone(runCtx, 440)
+		stop()
+	}
+	<-done
+}
+
+func TestParseTrack(t *testing.T) {
+	tests := []struct {
+		name   string
+		title  string
+		artist string
+		track  string
+		sep    string
+	}{
+		{"dash", "Queen - Bohemian Rhapsody", "Queen", "Bohemian Rhapsody", " - "},
+		{"en dash", "Daft Punk – One More Time", "Daft Punk", "One More Time", " – "},
+		{"em dash", "Björk — Hyperballad", "Björk", "Hyperballad", " — "},
+		{"pipe", "Muse | Uprising", "Muse", "Uprising", " | "},
+		{"multiple separators", "AC/DC - Back In Black - Live", "AC/DC", "Back In Black - Live", " - "},
+		{"mixed separators", "Air – Sexy Boy - Edit", "Air", "Sexy Boy - Edit", " – "},
+		{"separator in parentheses", "Hells Bells (Remastered - 2003)", "", "Hells Bells (Remastered - 2003)", ""},
+		{"suffix in brackets", "AC/DC - Hells Bells [Live - 1991]", "AC/DC", "Hells Bells [Live - 1991]", " - "},
+		{"no separator", "Station Jingle", "", "Station Jingle", ""},
+		{"hyphenated name", "Jay-Z - Encore", "Jay-Z", "Encore", " - "},
+		{"surrounding spaces", "  Adele - Hello  ", "Adele", "Hello", " - "},
+		{"empty", "", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseTrack(tt.title, DefaultTrackSeparators)
+			if got.Artist != tt.artist || got.Title != tt.track || got.Separator != tt.sep {
+				t.Errorf("parseTrack(%q) = artist %q, title %q, separator %q; want %q, %q, %q",
+					tt.title, got.Artist, got.Title, got.Separator, tt.artist, tt.track, tt.sep)
+			}
+			if got.Raw != tt.title {
+				t.Errorf("parseTrack(%q).Raw = %q", tt.title, got.Raw)
+			}
+		})
+	}
+}
+
+func TestParseTrackRegex(t *testing.T) {
+	tests := []struct {
+		name   string
+		re     string
+		title  string
+		artist string
+		track  string
+	}{
+		{
+			"tags around the title",
+			`^(?:\[[^\]]*\]\s*)?(?P<artist>.+?) - (?P<title>.+?)(?:\s*\[[^\]]*\])?$`,
+			"[NEW] Massive Attack - Teardrop [Explicit]", "Massive Attack", "Teardrop",
+		},
+		{
+			"quoted title by artist",
+			`^"(?P<title>.+)" by (?P<artist>.+)$`,
+			`"Paranoid Android" by Radiohead`, "Radiohead", "Paranoid Android",
+		},
+		{
+			"station prefix",
+			`^Now Playing: (?P<artist>.+?) - (?P<title>.+)$`,
+			"Now Playing: The Cure - Lullaby", "The Cure", "Lullaby",
+		},
+		{
+			"title first with slash",
+			`^(?P<title>.+?) / (?P<artist>.+)$`,
+			"Smooth Operator / Sade", "Sade", "Smooth Operator",
+		},
+		{
+			"title group only",
+			`^\d+\. (?P<title>.+)$`,
+			"07. Intermission", "", "Intermission",
+		},
+		{
+			"no match falls back to separators",
+			`^"(?P<title>.+)" by (?P<artist>.+)$`,
+			"Portishead - Roads", "Portishead", "Roads",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(Config{TrackRegex: regexp.MustCompile(tt.re)})
+			got := v.parseTrack(tt.title)
+			if got.Artist != tt.artist || got.Title != tt.track {
+				t.Errorf("parseTrack(%q) = artist %q, title %q; want %q, %q",
+					tt.title, got.Artist, got.Title, tt.artist, tt.track)
+			}
+			if got.Raw != tt.title {
+				t.Errorf("parseTrack(%q).Raw = %q", tt.title, got.Raw)
+			}
+		})
+	}
+}
+
+func TestClippingSquareWave(t *testing.T) {
+	v := New(Config{})
+	samples := make([]float64, 1024)
+	for i := range samples {
+		samples[i] = 0.5
+	}
+	v.Update(samples)
+	if v.Clipping() {
+		t.Fatal("Clipping() = true at half scale")
+	}
+
+	for i := range samples {
+		samples[i] = 1
+		if i/32%2 == 1 {
+			samples[i] = -1
+		}
+	}
+	v.Update(samples)
+	if !v.Clipping() {
+		t.Error("Clipping() = false for a full-scale square wave")
+	}
+}
+
+// TestSmoothFactorOne checks that SmoothFactor 1 disables smoothing: after a
+// loud chunk, a quiet one shows exactly its own values.
+func TestSmoothFactorOne(t *testing.T) {
+	v := New(Config{Width: 16, SmoothFactor: 1})
+	loud := make([]float64, 1024)
+	quiet := make([]float64, 1024)
+	for i := range loud {
+		loud[i] = 0.9
+		quiet[i] = 0.1
+	}
+
+	v.Update(loud)
+	v.Update(quiet)
+	for col, value := range v.GetWaveform() {
+		if math.Abs(value-0.1) > 1e-9 {
+			t.Fatalf("column %d = %v after a 0.1 chunk, want 0.1", col, value)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	samples := []float64{3, -4, 0, 1}
+	tests := []struct {
+		kind Aggregation
+		want float64
+	}{
+		{AggregationRMS, math.Sqrt((9 + 16 + 0 + 1) / 4.0)},
+		{AggregationPeak, 4},
+		{AggregationMean, (3 + 4 + 0 + 1) / 4.0},
+		{"", math.Sqrt((9 + 16 + 0 + 1) / 4.0)},
+	}
+
+	for _, tt := range tests {
+		if got := aggregate(samples, tt.kind); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("aggregate(%v, %q) = %v, want %v", samples, tt.kind, got, tt.want)
+		}
+	}
+}
+
+// TestStartFromToneKeepsFormat checks that a tone run, failed or not, leaves
+// the Config's sample format for the next stream.
+func TestStartFromToneKeepsFormat(t *testing.T) {
+	v := New(Config{Output: &syncBuffer{}, SampleFormat: FormatF32LE, Endianness: EndianBig})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	done := make(chan struct{})
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	go func() {
+		defer close(done)
+		_ = v.StartFromTone(ctx, 440)
+	}()
+	for !v.IsRunning() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := v.StartFromTone(ctx, 880); err != ErrAlreadyRunning {
+		t.Fatalf("second StartFromTone = %v, want ErrAlreadyRunning", err)
+	}
+	cfg := v.Config()
+	if cfg.SampleFormat != FormatF32LE || cfg.Endianness != EndianBig {
+		t.Errorf("config format = %s %s after StartFromTone, want f32le be", cfg.SampleFormat, cfg.Endianness)
+	}
+}
+
+// TestStreamURLClearedOnStop fetches the track while a URL stream runs, which
+// -race checks against start, and expects the URL to be gone afterwards.
+func TestStreamURLClearedOnStop(t *testing.T) {
+	v := New(Config{
+		Output:      &syncBuffer{},
+		FFprobePath: "true",
+		CommandFunc: func(ctx context.Context, streamURL string) *exec.Cmd {
+			return exec.CommandContext(ctx, "head", "-c", "65536", "/dev/zero")
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil {
+			v.FetchTrackContext(ctx)
+		}
+	}()
+
+	_ = v.StartFromURL(ctx, "http://radio.invalid/stream")
+	cancel()
+	<-done
+	if url := v.currentURL(); url != "" {
+		t.Errorf("stream URL = %q after the stream ended, want none", url)
+	}
+}
+
+// TestResetCorrelation plays opposite-phase stereo and expects Reset to
+// return the correlation to the 1 that mono reports.
+func TestResetCorrelation(t *testing.T) {
+	v := New(Config{Channels: 2})
+	if got := v.Correlation(); got != 1 {
+		t.Errorf("Correlation() = %v before any audio, want 1", got)
+	}
+
+	samples := make([]float64, 2048)
+	for i := 0; i < len(samples); i += 2 {
+		samples[i] = math.Sin(float64(i) / 10)
+		samples[i+1] = -samples[i]
+	}
+	v.Update(samples)
+	if got := v.Correlation(); got > -0.99 {
+		t.Fatalf("Correlation() = %v for opposite phase, want -1", got)
+	}
+
+	v.Reset()
+	if got := v.Correlation(); got != 1 {
+		t.Errorf("Correlation() = %v after Reset, want 1", got)
+	}
+}
+
+func TestValidateCharWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		char string
+		ok   bool
+	}{
+		{"ascii", "|", true},
+		{"block", "█", true},
+		{"braille", "⣿", true},
+		{"combined accent", "e\u0301", true},
+		{"wide", "全", false},
+		{"emoji", "🎵", false},
+		{"combining mark alone", "\u0301", false},
+		{"two runes", "ab", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for field, cfg := range map[string]Config{
+				"Char":        {Char: tt.char},
+				"BlankChar":   {BlankChar: tt.char},
+				"HeightChars": {HeightChars: []string{".", tt.char}},
+			} {
+				if err := cfg.Validate(); (err == nil) != tt.ok {
+					t.Errorf("%s %q: Validate() = %v, want ok %v", field, tt.char, err, tt.ok)
+				}
+			}
+		})
+	}
+}
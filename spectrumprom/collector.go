@@ -0,0 +1,89 @@
+// Package spectrumprom exports a Visualizer's level, frame rate and stream
+// health as Prometheus metrics. It lives in its own module so that the
+// spectrum package does not depend on the Prometheus client.
+package spectrumprom
+
+import (
+	"github.com/ant1kvar/spectrum"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	upDesc = prometheus.NewDesc("spectrum_up",
+		"Whether a stream is running (1) or not (0).", nil, nil)
+	levelDesc = prometheus.NewDesc("spectrum_level",
+		"RMS level of the latest chunk, 0 to 1.", nil, nil)
+	peakDesc = prometheus.NewDesc("spectrum_peak",
+		"Peak sample magnitude of the latest chunk, 0 to 1.", nil, nil)
+	fpsDesc = prometheus.NewDesc("spectrum_fps",
+		"Frames rendered over the last second.", nil, nil)
+	targetFPSDesc = prometheus.NewDesc("spectrum_target_fps",
+		"Configured frame rate.", nil, nil)
+	framesDesc = prometheus.NewDesc("spectrum_frames_total",
+		"Frames rendered.", nil, nil)
+	droppedDesc = prometheus.NewDesc("spectrum_dropped_frames_total",
+		"Frames skipped because the output was busy.", nil, nil)
+	reconnectsDesc = prometheus.NewDesc("spectrum_reconnects_total",
+		"ffmpeg restarts after a failure.", nil, nil)
+	samplesDesc = prometheus.NewDesc("spectrum_samples_total",
+		"Samples decoded, per channel.", nil, nil)
+	bytesDesc = prometheus.NewDesc("spectrum_bytes_total",
+		"PCM bytes decoded.", nil, nil)
+	trackDesc = prometheus.NewDesc("spectrum_track_info",
+		"The current track; always 1.", []string{"artist", "title"}, nil)
+)
+
+// collector reads the Visualizer on every scrape, so the values are as fresh
+// as the last processed chunk.
+type collector struct {
+	vis *spectrum.Visualizer
+}
+
+// NewCollector returns a Collector for vis. To export several visualizers
+// from one registry, register each through prometheus.WrapRegistererWith
+// with a distinguishing label.
+func NewCollector(vis *spectrum.Visualizer) prometheus.Collector {
+	return collector{vis: vis}
+}
+
+// Register adds a Collector for vis to reg.
+func Register(reg prometheus.Registerer, vis *spectrum.Visualizer) error {
+	return reg.Register(NewCollector(vis))
+}
+
+func (c collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc
+	ch <- levelDesc
+	ch <- peakDesc
+	ch <- fpsDesc
+	ch <- targetFPSDesc
+	ch <- framesDesc
+	ch <- droppedDesc
+	ch <- reconnectsDesc
+	ch <- samplesDesc
+	ch <- bytesDesc
+	ch <- trackDesc
+}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	up := 0.0
+	if c.vis.IsRunning() {
+		up = 1
+	}
+	stats := c.vis.Stats()
+	track := c.vis.GetTrack()
+
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up)
+	ch <- prometheus.MustNewConstMetric(levelDesc, prometheus.GaugeValue, c.vis.Level())
+	ch <- prometheus.MustNewConstMetric(peakDesc, prometheus.GaugeValue, c.vis.Peak())
+	ch <- prometheus.MustNewConstMetric(fpsDesc, prometheus.GaugeValue, stats.FPS)
+	ch <- prometheus.MustNewConstMetric(targetFPSDesc, prometheus.GaugeValue, float64(stats.TargetFPS))
+	ch <- prometheus.MustNewConstMetric(framesDesc, prometheus.CounterValue, float64(stats.Frames))
+	ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(stats.Dropped))
+	ch <- prometheus.MustNewConstMetric(reconnectsDesc, prometheus.CounterValue, float64(stats.Reconnects))
+	ch <- prometheus.MustNewConstMetric(samplesDesc, prometheus.CounterValue, float64(stats.Samples))
+	ch <- prometheus.MustNewConstMetric(bytesDesc, prometheus.CounterValue, float64(stats.Bytes))
+	if track.Raw != "" {
+		ch <- prometheus.MustNewConstMetric(trackDesc, prometheus.GaugeValue, 1, track.Artist, track.Title)
+	}
+}
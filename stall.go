@@ -0,0 +1,52 @@
+package spectrum
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// stallTimeout is how long the source may go without delivering a chunk
+// before StallDecay starts pulling the bars down.
+const stallTimeout = 200 * time.Millisecond
+
+// watchStall keeps rendering while reads are stalled, scaling the bars by
+// StallDecay every interval so a stuck stream settles to silence instead of
+// freezing on its last frame. lastChunk holds the UnixNano time of the
+// latest chunk.
+func (v *Visualizer) watchStall(ctx context.Context, lastChunk *atomic.Int64, interval time.Duration, render func() string, emit func(string) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if now.Sub(time.Unix(0, lastChunk.Load())) < stallTimeout || v.paused.Load() {
+				continue
+			}
+			if !v.decayStalled() {
+				continue
+			}
+			// A failed write surfaces in processStream once reads resume.
+			_ = emit(render())
+		}
+	}
+}
+
+// decayStalled applies one StallDecay step, reporting false when it is off.
+func (v *Visualizer) decayStalled() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	decay := v.config.StallDecay
+	if decay == 0 {
+		return false
+	}
+	for _, ch := range v.smoothed {
+		for i := range ch {
+			ch[i] *= decay
+		}
+	}
+	return true
+}
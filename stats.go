@@ -0,0 +1,128 @@
+package spectrum
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const statsWindow = time.Second
+
+// Stats describes how well the render loop keeps up. Averages cover frames
+// rendered within the last second; Read is time spent waiting for audio,
+// Process covers decoding, DSP and rendering, Write the output write. The
+// counters run for the lifetime of the Visualizer. Samples counts decoded
+// samples per channel, so one second of audio adds SampleRate whatever the
+// channel count, and Bytes the PCM they were decoded from; a finite source's
+// trailing partial chunk is not decoded.
+type Stats struct {
+	TargetFPS  int
+	FPS        float64
+	AvgFrame   time.Duration
+	AvgRead    time.Duration
+	AvgProcess time.Duration
+	AvgWrite   time.Duration
+	Frames     uint64
+	Overruns   uint64
+	Dropped    uint64
+	Reconnects uint64
+	Samples    uint64
+	Bytes      uint64
+}
+
+type frameTiming struct {
+	at                   time.Time
+	read, process, write time.Duration
+}
+
+type frameStats struct {
+	mu         sync.Mutex
+	recent     []frameTiming
+	frames     uint64
+	overruns   uint64
+	dropped    uint64
+	reconnects uint64
+
+	// samples and bytes are bumped for every chunk, so they skip the mutex.
+	samples atomic.Uint64
+	bytes   atomic.Uint64
+}
+
+func (s *frameStats) record(t frameTiming, overrun bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.frames++
+	if overrun {
+		s.overruns++
+	}
+	s.recent = append(s.trim(t.at), t)
+}
+
+// trim drops timings older than statsWindow.
+func (s *frameStats) trim(now time.Time) []frameTiming {
+	i := 0
+	for i < len(s.recent) && now.Sub(s.recent[i].at) > statsWindow {
+		i++
+	}
+	return append(s.recent[:0], s.recent[i:]...)
+}
+
+// decoded counts a chunk of samples per channel decoded from size bytes.
+func (s *frameStats) decoded(samples, size int) {
+	s.samples.Add(uint64(samples))
+	s.bytes.Add(uint64(size))
+}
+
+// drop counts a frame that DropFrames skipped because Output was busy.
+func (s *frameStats) drop() {
+	s.mu.Lock()
+	s.dropped++
+	s.mu.Unlock()
+}
+
+// reconnect counts an ffmpeg restart after a failure.
+func (s *frameStats) reconnect() {
+	s.mu.Lock()
+	s.reconnects++
+	s.mu.Unlock()
+}
+
+func (s *frameStats) snapshot(now time.Time) Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recent = s.trim(now)
+	stats := Stats{
+		Frames:     s.frames,
+		Overruns:   s.overruns,
+		Dropped:    s.dropped,
+		Reconnects: s.reconnects,
+		Samples:    s.samples.Load(),
+		Bytes:      s.bytes.Load(),
+	}
+	n := len(s.recent)
+	if n == 0 {
+		return stats
+	}
+
+	var read, process, write time.Duration
+	for _, t := range s.recent {
+		read += t.read
+		process += t.process
+		write += t.write
+	}
+	stats.FPS = float64(n) / statsWindow.Seconds()
+	stats.AvgRead = read / time.Duration(n)
+	stats.AvgProcess = process / time.Duration(n)
+	stats.AvgWrite = write / time.Duration(n)
+	stats.AvgFrame = stats.AvgRead + stats.AvgProcess + stats.AvgWrite
+	return stats
+}
+
+// Stats reports the measured frame rate and where frame time is spent.
+func (v *Visualizer) Stats() Stats {
+	stats := v.stats.snapshot(time.Now())
+	stats.TargetFPS = v.config.FPS
+	return stats
+}
@@ -0,0 +1,193 @@
+package spectrum
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// UnicodeTerminal guesses from the environment whether the terminal can show
+// block and Braille glyphs: TERM must not be "dumb" and the locale (LC_ALL,
+// LC_CTYPE or LANG, first one set) must be UTF-8. Use it to set
+// ASCIIFallback, e.g. cfg.ASCIIFallback = !spectrum.UnicodeTerminal().
+func UnicodeTerminal() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := os.Getenv(key); locale != "" {
+			locale = strings.ToLower(locale)
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return false
+}
+
+// wideRunes are the East Asian wide and fullwidth ranges, emoji included,
+// that terminals draw two columns wide.
+var wideRunes = [][2]rune{
+	{0x1100, 0x115f}, {0x231a, 0x231b}, {0x2329, 0x232a}, {0x23e9, 0x23ec},
+	{0x23f0, 0x23f0}, {0x23f3, 0x23f3}, {0x25fd, 0x25fe}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267f, 0x267f}, {0x2693, 0x2693}, {0x26a1, 0x26a1},
+	{0x26aa, 0x26ab}, {0x26bd, 0x26be}, {0x26c4, 0x26c5}, {0x26ce, 0x26ce},
+	{0x26d4, 0x26d4}, {0x26ea, 0x26ea}, {0x26f2, 0x26f3}, {0x26f5, 0x26f5},
+	{0x26fa, 0x26fa}, {0x26fd, 0x26fd}, {0x2705, 0x2705}, {0x270a, 0x270b},
+	{0x2728, 0x2728}, {0x274c, 0x274c}, {0x274e, 0x274e}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27b0, 0x27b0}, {0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c}, {0x2b50, 0x2b50}, {0x2b55, 0x2b55}, {0x2e80, 0x303e},
+	{0x3041, 0x33ff}, {0x3400, 0x4dbf}, {0x4e00, 0x9fff}, {0xa000, 0xa4cf},
+	{0xa960, 0xa97f}, {0xac00, 0xd7a3}, {0xf900, 0xfaff}, {0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f}, {0xff00, 0xff60}, {0xffe0, 0xffe6}, {0x16fe0, 0x18aff},
+	{0x1b000, 0x1b2ff}, {0x1f004, 0x1f004}, {0x1f0cf, 0x1f0cf}, {0x1f18e, 0x1f18e},
+	{0x1f191, 0x1f19a}, {0x1f200, 0x1f251}, {0x1f300, 0x1f64f}, {0x1f680, 0x1f6ff},
+	{0x1f7e0, 0x1f7eb}, {0x1f90c, 0x1f9ff}, {0x1fa70, 0x1faff}, {0x20000, 0x3fffd},
+}
+
+// displayWidth returns the terminal columns s covers: combining marks,
+// format and control characters take none and wide runes take two.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Cc):
+		case isWide(r):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+func isWide(r rune) bool {
+	for _, span := range wideRunes {
+		if r < span[0] {
+			return false
+		}
+		if r <= span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+var virtualTerminalOnce sync.Once
+
+// EnableVirtualTerminal switches the Windows console to interpret the ANSI
+// escapes used for colors and cursor movement, which stock cmd.exe prints
+// literally otherwise. Streaming, ClearScreen and ShowCursor call it once
+// automatically; elsewhere it does nothing.
+func EnableVirtualTerminal() error {
+	return enableVirtualTerminal()
+}
+
+func autoVirtualTerminal() {
+	virtualTerminalOnce.Do(func() { _ = enableVirtualTerminal() })
+}
+
+func (v *Visualizer) terminal() *os.File {
+	if f, ok := v.config.Output.(*os.File); ok {
+		return f
+	}
+	return os.Stdout
+}
+
+// fitTerminal sizes the display to the terminal. The first row is left for
+// the caller's "now playing" line and the last rows for the axis and status
+// lines.
+func (v *Visualizer) fitTerminal() {
+	cols, rows, err := terminalSize(v.terminal())
+	if err != nil || cols <= 0 || rows <= 0 {
+		return
+	}
+
+	v.mu.RLock()
+	rows -= 1 + v.extraRows()
+	v.mu.RUnlock()
+	v.resize(cols, max(rows, 1))
+}
+
+// watchResize refits the display with AutoSize and clears the frame's
+// region on every resize, since the terminal reflows the old frame's rows
+// and leaves stray cells behind.
+func (v *Visualizer) watchResize(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	notifyResize(sigCh)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			if v.config.AutoSize {
+				v.fitTerminal()
+			}
+			v.ForceClear()
+		}
+	}
+}
+
+// ForceClear wipes the frame's region, from OriginRow down to the bottom of
+// the screen, before the next frame is written. Resizes do this on their
+// own; call it after other output has drawn over the visualizer. It does
+// nothing with RawFrame.
+func (v *Visualizer) ForceClear() {
+	v.clear.Store(true)
+	v.redraw.Store(true)
+}
+
+// clearRegion is the escape sequence ForceClear writes ahead of a frame.
+func (v *Visualizer) clearRegion() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.config.RawFrame {
+		return ""
+	}
+	return fmt.Sprintf("\033[%d;%dH\033[J", v.config.OriginRow, v.config.OriginCol)
+}
+
+func (v *Visualizer) resize(width, height int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if width == v.config.Width && height == v.config.Height {
+		return
+	}
+	v.config.Width = width
+	v.config.Height = height
+	v.configure()
+	v.clear.Store(true)
+}
+
+// resample stretches or squeezes values to n entries with linear
+// interpolation so smoothing state survives a resize.
+func resample(values []float64, n int) []float64 {
+	result := make([]float64, n)
+	if len(values) == 0 {
+		return result
+	}
+	if len(values) == 1 || n == 1 {
+		for i := range result {
+			result[i] = values[0]
+		}
+		return result
+	}
+
+	scale := float64(len(values)-1) / float64(n-1)
+	for i := range result {
+		pos := float64(i) * scale
+		j := int(pos)
+		if j >= len(values)-1 {
+			result[i] = values[len(values)-1]
+			continue
+		}
+		frac := pos - float64(j)
+		result[i] = values[j]*(1-frac) + values[j+1]*frac
+	}
+	return result
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package spectrum
+
+import (
+	"errors"
+	"os"
+)
+
+func terminalSize(f *os.File) (cols, rows int, err error) {
+	return 0, 0, errors.New("terminal size is not supported on this platform")
+}
+
+func notifyResize(ch chan<- os.Signal) {}
+
+func enableVirtualTerminal() error { return nil }
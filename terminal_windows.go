@@ -0,0 +1,40 @@
+//go:build windows
+
+package spectrum
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+const enableVirtualTerminalProcessing = 0x0004
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+func terminalSize(f *os.File) (cols, rows int, err error) {
+	return 0, 0, errors.New("terminal size is not supported on this platform")
+}
+
+func notifyResize(ch chan<- os.Signal) {}
+
+// enableVirtualTerminal turns on escape sequence processing for stdout and
+// stderr. Handles that are not consoles, such as redirected output, are
+// left alone.
+func enableVirtualTerminal() error {
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		h := syscall.Handle(f.Fd())
+		var mode uint32
+		if err := syscall.GetConsoleMode(h, &mode); err != nil {
+			continue
+		}
+		if mode&enableVirtualTerminalProcessing != 0 {
+			continue
+		}
+		if ok, _, err := procSetConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing)); ok == 0 {
+			return fmt.Errorf("enable virtual terminal processing: %w", err)
+		}
+	}
+	return nil
+}
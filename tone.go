@@ -0,0 +1,57 @@
+package spectrum
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+)
+
+// ToneReader is an endless s16le sine wave, the same sample on every
+// channel, for checking a setup without any audio source.
+type ToneReader struct {
+	Freq       float64
+	SampleRate int
+	Channels   int
+	// Amplitude is the peak level, 0 to 1.
+	Amplitude float64
+
+	frame  int64
+	offset int
+	sample [2]byte
+}
+
+// NewToneReader returns a ToneReader at half of full scale.
+func NewToneReader(freq float64, sampleRate, channels int) *ToneReader {
+	return &ToneReader{Freq: freq, SampleRate: sampleRate, Channels: channels, Amplitude: 0.5}
+}
+
+// Read fills p completely; reads may end mid-sample and the next one picks
+// up where it left off.
+func (t *ToneReader) Read(p []byte) (int, error) {
+	frameSize := 2 * t.Channels
+	for i := range p {
+		if t.offset%2 == 0 {
+			phase := 2 * math.Pi * t.Freq * float64(t.frame) / float64(t.SampleRate)
+			binary.LittleEndian.PutUint16(t.sample[:], uint16(int16(t.Amplitude*math.Sin(phase)*math.MaxInt16)))
+		}
+		p[i] = t.sample[t.offset%2]
+		t.offset++
+		if t.offset == frameSize {
+			t.offset = 0
+			t.frame++
+		}
+	}
+	return len(p), nil
+}
+
+// StartFromTone visualizes a sine wave at freq Hz played in real time at
+// the Config's SampleRate and Channels. The tone is decoded as s16le
+// whatever the Config's SampleFormat; the Config itself is left alone. In
+// ModeSpectrum a 1 kHz tone lights the column that FrequencyForColumn puts
+// nearest 1000 Hz.
+func (v *Visualizer) StartFromTone(ctx context.Context, freq float64) error {
+	tone := NewToneReader(freq, v.config.SampleRate, v.config.Channels)
+	bytesPerSecond := v.config.SampleRate * v.config.Channels * FormatS16LE.bytesPerSample()
+	pcm := pcmFormat{format: FormatS16LE, endian: EndianLittle}
+	return v.startFromReader(ctx, &pacedReader{r: tone, bytesPerSecond: bytesPerSecond}, pcm, false)
+}
@@ -0,0 +1,161 @@
+package spectrum
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xfffe
+)
+
+type wavHeader struct {
+	format        uint16
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+	// dataSize is the length of the data chunk in bytes, or -1 when the
+	// writer left it open (0xffffffff) and the samples run to EOF.
+	dataSize int64
+}
+
+func (h wavHeader) sampleFormat() (SampleFormat, error) {
+	switch {
+	case h.format == wavFormatPCM && h.bitsPerSample == 16:
+		return FormatS16LE, nil
+	case h.format == wavFormatPCM && h.bitsPerSample == 24:
+		return FormatS24LE, nil
+	case h.format == wavFormatPCM && h.bitsPerSample == 32:
+		return FormatS32LE, nil
+	case h.format == wavFormatFloat && h.bitsPerSample == 32:
+		return FormatF32LE, nil
+	}
+	return "", fmt.Errorf("unsupported wav encoding: format %d, %d bits", h.format, h.bitsPerSample)
+}
+
+// StartFromWAV plays a PCM or float WAV file through the visualizer in real
+// time without ffmpeg and returns nil at the end of the file. The file's
+// channel count and sample rate must match the Config; its sample format is
+// picked up from the header for this run only, leaving the Config's
+// SampleFormat for later streams.
+func (v *Visualizer) StartFromWAV(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return v.StartFromWAVReader(ctx, f)
+}
+
+func (v *Visualizer) StartFromWAVReader(ctx context.Context, r io.Reader) error {
+	hdr, err := readWAVHeader(r)
+	if err != nil {
+		return err
+	}
+
+	format, err := hdr.sampleFormat()
+	if err != nil {
+		return err
+	}
+	cfg := v.Config()
+	if hdr.channels != cfg.Channels {
+		return fmt.Errorf("wav has %d channels, config expects %d", hdr.channels, cfg.Channels)
+	}
+	if hdr.sampleRate != cfg.SampleRate {
+		return fmt.Errorf("wav sample rate is %d Hz, config expects %d Hz", hdr.sampleRate, cfg.SampleRate)
+	}
+	// Stop at the end of the data chunk so trailing LIST, id3 or cue chunks
+	// are not played as samples.
+	if hdr.dataSize >= 0 {
+		r = io.LimitReader(r, hdr.dataSize)
+	}
+
+	bytesPerSecond := hdr.sampleRate * hdr.channels * format.bytesPerSample()
+	pcm := pcmFormat{format: format, endian: EndianLittle}
+	return v.startFromReader(ctx, &pacedReader{r: r, bytesPerSecond: bytesPerSecond}, pcm, true)
+}
+
+// readWAVHeader consumes the RIFF header up to the start of the data chunk
+// and records the chunk's size.
+func readWAVHeader(r io.Reader) (wavHeader, error) {
+	var hdr wavHeader
+
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return hdr, fmt.Errorf("failed to read wav header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return hdr, errors.New("not a RIFF/WAVE file")
+	}
+
+	haveFormat := false
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return hdr, fmt.Errorf("failed to read wav chunk: %w", err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return hdr, errors.New("wav fmt chunk is too short")
+			}
+			data := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return hdr, fmt.Errorf("failed to read wav fmt chunk: %w", err)
+			}
+			hdr.format = binary.LittleEndian.Uint16(data[0:2])
+			hdr.channels = int(binary.LittleEndian.Uint16(data[2:4]))
+			hdr.sampleRate = int(binary.LittleEndian.Uint32(data[4:8]))
+			hdr.bitsPerSample = int(binary.LittleEndian.Uint16(data[14:16]))
+			if hdr.format == wavFormatExtensible && size >= 26 {
+				hdr.format = binary.LittleEndian.Uint16(data[24:26])
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return hdr, errors.New("wav data chunk precedes fmt chunk")
+			}
+			hdr.dataSize = size
+			if size == 0xffffffff {
+				hdr.dataSize = -1
+			}
+			return hdr, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return hdr, fmt.Errorf("failed to skip wav chunk %q: %w", id, err)
+			}
+		}
+	}
+}
+
+// pacedReader limits reads to bytesPerSecond so files play back in real time.
+type pacedReader struct {
+	r              io.Reader
+	bytesPerSecond int
+	start          time.Time
+	read           int64
+}
+
+func (p *pacedReader) Read(b []byte) (int, error) {
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+
+	due := time.Duration(p.read) * time.Second / time.Duration(p.bytesPerSecond)
+	time.Sleep(due - time.Since(p.start))
+	return n, err
+}
@@ -0,0 +1,76 @@
+package spectrum
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+)
+
+// wavFile builds a 16-bit PCM WAV holding samples, interleaved when
+// channels is 2.
+func wavFile(sampleRate, channels int, samples []int16) []byte {
+	var data bytes.Buffer
+	_ = binary.Write(&data, binary.LittleEndian, samples)
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+data.Len()))
+	buf.WriteString("WAVEfmt ")
+	for _, field := range []any{
+		uint32(16), uint16(1), uint16(channels), uint32(sampleRate),
+		uint32(sampleRate * channels * 2), uint16(channels * 2), uint16(16),
+	} {
+		_ = binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(data.Len()))
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+// TestStartFromWAVKeepsFormat plays an s16 WAV with the Config set to f32be
+// and expects the file to decode while the Config keeps its format.
+func TestStartFromWAVKeepsFormat(t *testing.T) {
+	samples := make([]int16, 4096)
+	for i := range samples {
+		samples[i] = 16384
+	}
+	v := New(Config{Output: &syncBuffer{}, ChunkSize: 1024, LowLatency: true, SampleFormat: FormatF32LE, Endianness: EndianBig})
+
+	if err := v.StartFromWAVReader(context.Background(), bytes.NewReader(wavFile(44100, 1, samples))); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Stats().Samples; got != uint64(len(samples)) {
+		t.Errorf("decoded %d samples, want %d", got, len(samples))
+	}
+	if level := v.Level(); level != 0.5 {
+		t.Errorf("Level() = %v, want 0.5 for s16 samples at half scale", level)
+	}
+	cfg := v.Config()
+	if cfg.SampleFormat != FormatF32LE || cfg.Endianness != EndianBig {
+		t.Errorf("config format = %s %s after StartFromWAVReader, want f32le be", cfg.SampleFormat, cfg.Endianness)
+	}
+}
+
+// TestStartFromWAVStopsAtDataEnd appends a LIST chunk after the samples and
+// expects it to be left out of the decoded audio.
+func TestStartFromWAVStopsAtDataEnd(t *testing.T) {
+	samples := make([]int16, 4096)
+	wav := wavFile(44100, 1, samples)
+	// A full chunk's worth of metadata, so it would be decoded if read.
+	info := []byte("INFOICMT")
+	info = binary.LittleEndian.AppendUint32(info, 2048)
+	info = append(info, bytes.Repeat([]byte("x"), 2048)...)
+	wav = append(wav, "LIST"...)
+	wav = binary.LittleEndian.AppendUint32(wav, uint32(len(info)))
+	wav = append(wav, info...)
+	v := New(Config{Output: &syncBuffer{}, ChunkSize: 1024, LowLatency: true})
+
+	if err := v.StartFromWAVReader(context.Background(), bytes.NewReader(wav)); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.Stats().Samples; got != uint64(len(samples)) {
+		t.Errorf("decoded %d samples, want %d", got, len(samples))
+	}
+}